@@ -0,0 +1,50 @@
+package capture
+
+import (
+	"context"
+	"time"
+
+	"github.com/das08/utils/pkg/queue"
+	"github.com/go-redis/redis/v8"
+)
+
+// Backend is how capture events get from galactus to the bot. Pop returns redis.Nil if no event arrives before the
+// timeout, and Ack must be called once an event has been handled (backends that can't redeliver treat it as a no-op).
+type Backend interface {
+	Push(ctx context.Context, connCode string, eventType EventType, payload string) error
+	Pop(ctx context.Context, connCode string, timeout time.Duration) (Event, error)
+	Ack(ctx context.Context, connCode string, event Event) error
+}
+
+// ListBackend is the original list-based transport: each event is read by exactly one consumer, and is gone once read
+type ListBackend struct {
+	q queue.Queue
+}
+
+func NewListBackend(client *redis.Client) *ListBackend {
+	return &ListBackend{q: queue.NewRedis(client)}
+}
+
+// NewQueueBackend is a ListBackend on any queue.Queue, such as queue.NewMemory() for running without Redis
+func NewQueueBackend(q queue.Queue) *ListBackend {
+	return &ListBackend{q: q}
+}
+
+func (b *ListBackend) Push(ctx context.Context, connCode string, eventType EventType, payload string) error {
+	return pushEvent(ctx, b.q, connCode, eventType, payload)
+}
+
+func (b *ListBackend) Pop(ctx context.Context, connCode string, timeout time.Duration) (Event, error) {
+	return popEvent(ctx, b.q, connCode, timeout)
+}
+
+// Ack is a no-op: an event is removed from the list as soon as it is popped, so there is nothing to acknowledge and an
+// event lost by a crashed consumer can't be redelivered. Use a StreamBackend for that.
+func (b *ListBackend) Ack(ctx context.Context, connCode string, event Event) error {
+	return nil
+}
+
+var (
+	_ Backend = (*ListBackend)(nil)
+	_ Backend = (*StreamBackend)(nil)
+)
@@ -3,9 +3,9 @@ package capture
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"time"
 
+	"github.com/das08/utils/pkg/queue"
 	"github.com/das08/utils/pkg/rediskey"
 	"github.com/go-redis/redis/v8"
 )
@@ -23,11 +23,22 @@ const (
 type Event struct {
 	EventType EventType `json:"type"`
 	Payload   []byte    `json:"payload"`
+
+	// ID is the stream entry ID when the event was read from a StreamBackend
+	ID string `json:"-"`
 }
 
 const EventTTLSeconds = 3600
 
 func PushEvent(ctx context.Context, redis *redis.Client, connCode string, jobType EventType, payload string) error {
+	return pushEvent(ctx, queue.NewRedis(redis), connCode, jobType, payload)
+}
+
+func PopRawEvent(ctx context.Context, redis *redis.Client, connCode string, timeout time.Duration) (string, error) {
+	return queue.NewRedis(redis).BLPop(ctx, timeout, rediskey.EventsNamespace+connCode)
+}
+
+func pushEvent(ctx context.Context, q queue.Queue, connCode string, jobType EventType, payload string) error {
 	event := Event{
 		EventType: jobType,
 		Payload:   []byte(payload),
@@ -37,26 +48,16 @@ func PushEvent(ctx context.Context, redis *redis.Client, connCode string, jobTyp
 		return err
 	}
 
-	count, err := redis.RPush(ctx, rediskey.EventsNamespace+connCode, string(jBytes)).Result()
-
-	// new list
-	if count < 2 {
-		// log.Printf("Set TTL for List")
-		redis.Expire(ctx, rediskey.EventsNamespace+connCode, EventTTLSeconds*time.Second)
-	}
-
+	_, err = q.RPushTTL(ctx, rediskey.EventsNamespace+connCode, EventTTLSeconds*time.Second, string(jBytes))
 	return err
 }
 
-func PopRawEvent(ctx context.Context, redis *redis.Client, connCode string, timeout time.Duration) (string, error) {
-	elems, err := redis.BLPop(ctx, timeout, rediskey.EventsNamespace+connCode).Result()
+func popEvent(ctx context.Context, q queue.Queue, connCode string, timeout time.Duration) (Event, error) {
+	event := Event{}
+	str, err := q.BLPop(ctx, timeout, rediskey.EventsNamespace+connCode)
 	if err != nil {
-		return "", err
-	}
-
-	if len(elems) < 2 {
-		return "", errors.New("insufficient elements returned")
-	} else {
-		return elems[1], nil
+		return event, err
 	}
+	err = json.Unmarshal([]byte(str), &event)
+	return event, err
 }
@@ -0,0 +1,99 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/das08/utils/pkg/game"
+	"github.com/das08/utils/pkg/queue"
+	"github.com/go-redis/redis/v8"
+)
+
+var ErrEventTypeMismatch = errors.New("event type does not match the requested payload")
+
+func (t EventType) String() string {
+	return game.PayloadKind(int(t))
+}
+
+func PushConnectionEvent(ctx context.Context, redis *redis.Client, connCode string, connected bool) error {
+	return PushEvent(ctx, redis, connCode, Connection, strconv.FormatBool(connected))
+}
+
+func PushLobbyEvent(ctx context.Context, redis *redis.Client, connCode string, lobby game.Lobby) error {
+	return pushJSONEvent(ctx, redis, connCode, Lobby, lobby)
+}
+
+func PushStateEvent(ctx context.Context, redis *redis.Client, connCode string, phase game.Phase) error {
+	return PushEvent(ctx, redis, connCode, State, strconv.Itoa(int(phase)))
+}
+
+func PushPlayerEvent(ctx context.Context, redis *redis.Client, connCode string, player game.Player) error {
+	return pushJSONEvent(ctx, redis, connCode, Player, player)
+}
+
+func PushGameOverEvent(ctx context.Context, redis *redis.Client, connCode string, gameover game.Gameover) error {
+	return pushJSONEvent(ctx, redis, connCode, GameOver, gameover)
+}
+
+func pushJSONEvent(ctx context.Context, redis *redis.Client, connCode string, eventType EventType, v interface{}) error {
+	payload, err := game.MarshalPayload(v)
+	if err != nil {
+		return err
+	}
+	return PushEvent(ctx, redis, connCode, eventType, payload)
+}
+
+// PopEvent is PopRawEvent, but returns the decoded Event
+func PopEvent(ctx context.Context, redis *redis.Client, connCode string, timeout time.Duration) (Event, error) {
+	return popEvent(ctx, queue.NewRedis(redis), connCode, timeout)
+}
+
+func (e *Event) payload(expected EventType) ([]byte, error) {
+	if e.EventType != expected {
+		return nil, fmt.Errorf("%w: wanted a %s event, but got a %s event", ErrEventTypeMismatch, expected, e.EventType)
+	}
+	return e.Payload, nil
+}
+
+func (e *Event) DecodeConnection() (bool, error) {
+	data, err := e.payload(Connection)
+	if err != nil {
+		return false, err
+	}
+	return game.UnmarshalConnection(data)
+}
+
+func (e *Event) DecodeLobby() (game.Lobby, error) {
+	data, err := e.payload(Lobby)
+	if err != nil {
+		return game.Lobby{}, err
+	}
+	return game.UnmarshalLobby(data)
+}
+
+func (e *Event) DecodePhase() (game.Phase, error) {
+	data, err := e.payload(State)
+	if err != nil {
+		return game.UNINITIALIZED, err
+	}
+	return game.UnmarshalPhase(data)
+}
+
+func (e *Event) DecodePlayer() (game.Player, error) {
+	data, err := e.payload(Player)
+	if err != nil {
+		return game.Player{}, err
+	}
+	return game.UnmarshalPlayer(data)
+}
+
+func (e *Event) DecodeGameOver() (game.Gameover, error) {
+	data, err := e.payload(GameOver)
+	if err != nil {
+		return game.Gameover{}, err
+	}
+	return game.UnmarshalGameover(data)
+}
@@ -0,0 +1,84 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/das08/utils/pkg/game"
+)
+
+func TestTypedEventRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	client := newTestRedis(t)
+
+	player := game.Player{Action: game.JOINED, Name: "red", Color: game.Red}
+	if err := PushPlayerEvent(ctx, client, "code", player); err != nil {
+		t.Fatal(err)
+	}
+	if err := PushConnectionEvent(ctx, client, "code", true); err != nil {
+		t.Fatal(err)
+	}
+	gameover := game.Gameover{GameOverReason: game.HumansByVote, PlayerInfos: []game.PlayerInfo{{Name: "red"}}}
+	if err := PushGameOverEvent(ctx, client, "code", gameover); err != nil {
+		t.Fatal(err)
+	}
+
+	event, err := PopEvent(ctx, client, "code", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := event.DecodePlayer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded != player {
+		t.Errorf("expected %+v, got %+v", player, decoded)
+	}
+
+	event, err = PopEvent(ctx, client, "code", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := event.DecodeLobby(); !errors.Is(err, ErrEventTypeMismatch) {
+		t.Errorf("expected ErrEventTypeMismatch decoding a connection event as a lobby, got %v", err)
+	}
+	if connected, err := event.DecodeConnection(); err != nil || !connected {
+		t.Errorf("expected a connected event, got %v, %v", connected, err)
+	}
+
+	event, err = PopEvent(ctx, client, "code", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	over, err := event.DecodeGameOver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if over.GameOverReason != gameover.GameOverReason || len(over.PlayerInfos) != 1 {
+		t.Errorf("expected %+v, got %+v", gameover, over)
+	}
+}
+
+func TestDecodeEventMismatchedShape(t *testing.T) {
+	// a player payload mislabelled as a gameover event
+	event := Event{EventType: GameOver, Payload: []byte(`{"Action":0,"Name":"red","Color":0}`)}
+	if _, err := event.DecodeGameOver(); !errors.Is(err, game.ErrPayloadShape) {
+		t.Errorf("expected ErrPayloadShape, got %v", err)
+	}
+
+	event = Event{EventType: State, Payload: []byte("42")}
+	if _, err := event.DecodePhase(); !errors.Is(err, game.ErrPayloadShape) {
+		t.Errorf("expected ErrPayloadShape for an out of range phase, got %v", err)
+	}
+
+	event = Event{EventType: Connection, Payload: []byte("maybe")}
+	if _, err := event.DecodeConnection(); !errors.Is(err, game.ErrPayloadShape) {
+		t.Errorf("expected ErrPayloadShape for a non-boolean connection, got %v", err)
+	}
+
+	if State.String() != "state" || EventType(9).String() != "unknown(9)" {
+		t.Errorf("unexpected event type names %s, %s", State, EventType(9))
+	}
+}
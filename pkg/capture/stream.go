@@ -0,0 +1,217 @@
+package capture
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/das08/utils/pkg/rediskey"
+	"github.com/go-redis/redis/v8"
+)
+
+const DefaultStreamMaxLen = 1000
+
+// StreamBackend stores events in a Redis Stream per connect code. Every consumer group sees every event, so the muting
+// worker and a stats recorder can each read the same game independently, and events can be replayed until trimmed.
+type StreamBackend struct {
+	client   *redis.Client
+	group    string
+	consumer string
+
+	// MaxLen caps the length of each stream (approximately, so Redis can trim efficiently). 0 disables trimming.
+	MaxLen int64
+
+	groups sync.Map
+}
+
+func NewStreamBackend(client *redis.Client, group, consumer string) *StreamBackend {
+	return &StreamBackend{
+		client:   client,
+		group:    group,
+		consumer: consumer,
+		MaxLen:   DefaultStreamMaxLen,
+	}
+}
+
+// Push adds the event and refreshes the stream's TTL in one transaction, so a stream is never left without a TTL
+func (b *StreamBackend) Push(ctx context.Context, connCode string, eventType EventType, payload string) error {
+	stream := rediskey.EventsStream(connCode)
+	_, err := b.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream:       stream,
+			MaxLenApprox: b.MaxLen,
+			Values: map[string]interface{}{
+				"type":    int(eventType),
+				"payload": payload,
+			},
+		})
+		pipe.Expire(ctx, stream, EventTTLSeconds*time.Second)
+		return nil
+	})
+	return err
+}
+
+// Pop reads the next event this backend's group hasn't seen yet. A timeout of 0 blocks until an event arrives.
+func (b *StreamBackend) Pop(ctx context.Context, connCode string, timeout time.Duration) (Event, error) {
+	stream := rediskey.EventsStream(connCode)
+	if err := b.ensureGroup(ctx, stream); err != nil {
+		return Event{}, err
+	}
+
+	args := &redis.XReadGroupArgs{
+		Group:    b.group,
+		Consumer: b.consumer,
+		Streams:  []string{stream, ">"},
+		Count:    1,
+		Block:    timeout,
+	}
+	streams, err := b.client.XReadGroup(ctx, args).Result()
+	if isNoGroup(err) {
+		// the stream expired and took the group with it, so start again
+		b.groups.Delete(stream)
+		if err := b.ensureGroup(ctx, stream); err != nil {
+			return Event{}, err
+		}
+		streams, err = b.client.XReadGroup(ctx, args).Result()
+	}
+	if err != nil {
+		return Event{}, err
+	}
+	if len(streams) < 1 || len(streams[0].Messages) < 1 {
+		return Event{}, redis.Nil
+	}
+	return eventFromMessage(streams[0].Messages[0])
+}
+
+func (b *StreamBackend) Ack(ctx context.Context, connCode string, event Event) error {
+	return b.client.XAck(ctx, rediskey.EventsStream(connCode), b.group, event.ID).Err()
+}
+
+// Reclaim takes over up to count events that were read by any consumer in this backend's group, but haven't been acked
+// within minIdle, such as those read by a worker that crashed. The events are delivered to this backend's consumer and
+// must be acked like any other.
+func (b *StreamBackend) Reclaim(ctx context.Context, connCode string, minIdle time.Duration, count int64) ([]Event, error) {
+	stream := rediskey.EventsStream(connCode)
+	pending, err := b.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: stream,
+		Group:  b.group,
+		Start:  "-",
+		End:    "+",
+		Count:  count,
+	}).Result()
+	if isNoGroup(err) {
+		// nothing can be pending in a group that no longer exists
+		b.groups.Delete(stream)
+		return nil, nil
+	}
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(pending))
+	for _, p := range pending {
+		if p.Idle >= minIdle {
+			ids = append(ids, p.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	msgs, err := b.client.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   stream,
+		Group:    b.group,
+		Consumer: b.consumer,
+		MinIdle:  minIdle,
+		Messages: ids,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	events := make([]Event, 0, len(msgs))
+	for _, msg := range msgs {
+		event, err := eventFromMessage(msg)
+		if err != nil {
+			return events, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// Replay returns up to count events starting at (and including) fromID, regardless of whether any group has read or
+// acked them. Use "-" to replay from the start of the stream, and a count of 0 for no limit.
+func (b *StreamBackend) Replay(ctx context.Context, connCode, fromID string, count int64) ([]Event, error) {
+	stream := rediskey.EventsStream(connCode)
+	var msgs []redis.XMessage
+	var err error
+	if count > 0 {
+		msgs, err = b.client.XRangeN(ctx, stream, fromID, "+", count).Result()
+	} else {
+		msgs, err = b.client.XRange(ctx, stream, fromID, "+").Result()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]Event, 0, len(msgs))
+	for _, msg := range msgs {
+		event, err := eventFromMessage(msg)
+		if err != nil {
+			return events, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// Trim drops all but the most recent maxLen events for a connect code
+func (b *StreamBackend) Trim(ctx context.Context, connCode string, maxLen int64) (int64, error) {
+	return b.client.XTrim(ctx, rediskey.EventsStream(connCode), maxLen).Result()
+}
+
+// ensureGroup creates the consumer group on first use. The group starts at the beginning of the stream, so events
+// pushed before the first Pop aren't missed. Streams expire along with their groups, so callers that get NOGROUP must
+// forget the stream and call this again.
+func (b *StreamBackend) ensureGroup(ctx context.Context, stream string) error {
+	if _, ok := b.groups.Load(stream); ok {
+		return nil
+	}
+	err := b.client.XGroupCreateMkStream(ctx, stream, b.group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	if err == nil {
+		b.client.Expire(ctx, stream, EventTTLSeconds*time.Second)
+	}
+	b.groups.Store(stream, struct{}{})
+	return nil
+}
+
+func isNoGroup(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "NOGROUP")
+}
+
+func eventFromMessage(msg redis.XMessage) (Event, error) {
+	event := Event{ID: msg.ID}
+
+	typeStr, ok := msg.Values["type"].(string)
+	if !ok {
+		return event, fmt.Errorf("stream entry %s has no event type", msg.ID)
+	}
+	eventType, err := strconv.Atoi(typeStr)
+	if err != nil {
+		return event, fmt.Errorf("stream entry %s has an invalid event type: %w", msg.ID, err)
+	}
+	event.EventType = EventType(eventType)
+
+	payload, _ := msg.Values["payload"].(string)
+	event.Payload = []byte(payload)
+	return event, nil
+}
@@ -0,0 +1,166 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/das08/utils/pkg/rediskey"
+	"github.com/go-redis/redis/v8"
+)
+
+func newTestRedis(t *testing.T) *redis.Client {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestStreamBackendGroups(t *testing.T) {
+	ctx := context.Background()
+	client := newTestRedis(t)
+
+	muter := NewStreamBackend(client, "muter", "bot1")
+	stats := NewStreamBackend(client, "stats", "recorder1")
+
+	if err := muter.Push(ctx, "ABCDEF", State, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := muter.Push(ctx, "ABCDEF", State, "2"); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := client.TTL(ctx, rediskey.EventsStream("ABCDEF")).Val(); ttl <= 0 {
+		t.Errorf("expected the stream to have a TTL, got %s", ttl)
+	}
+
+	// each group sees every event
+	for _, backend := range []*StreamBackend{muter, stats} {
+		for _, expected := range []string{"1", "2"} {
+			event, err := backend.Pop(ctx, "ABCDEF", time.Millisecond*10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if event.EventType != State || string(event.Payload) != expected {
+				t.Errorf("%s: expected state event %s, got %+v", backend.group, expected, event)
+			}
+			if err := backend.Ack(ctx, "ABCDEF", event); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := backend.Pop(ctx, "ABCDEF", time.Millisecond*10); !errors.Is(err, redis.Nil) {
+			t.Errorf("%s: expected redis.Nil once the stream is drained, got %v", backend.group, err)
+		}
+	}
+}
+
+func TestStreamBackendReplayAndTrim(t *testing.T) {
+	ctx := context.Background()
+	client := newTestRedis(t)
+	backend := NewStreamBackend(client, "muter", "bot1")
+
+	for _, payload := range []string{"0", "1", "2", "3"} {
+		if err := backend.Push(ctx, "ABCDEF", State, payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, err := backend.Replay(ctx, "ABCDEF", "-", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events, got %d", len(events))
+	}
+
+	events, err = backend.Replay(ctx, "ABCDEF", events[2].ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || string(events[0].Payload) != "2" {
+		t.Errorf("expected to replay from the third event, got %+v", events)
+	}
+
+	if _, err := backend.Trim(ctx, "ABCDEF", 1); err != nil {
+		t.Fatal(err)
+	}
+	events, err = backend.Replay(ctx, "ABCDEF", "-", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || string(events[0].Payload) != "3" {
+		t.Errorf("expected only the latest event after trimming, got %+v", events)
+	}
+}
+
+func TestStreamBackendRecreatesExpiredGroup(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	backend := NewStreamBackend(client, "muter", "bot1")
+
+	if err := backend.Push(ctx, "ABCDEF", State, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := backend.Pop(ctx, "ABCDEF", time.Millisecond*10); err != nil {
+		t.Fatal(err)
+	}
+
+	// the stream expires along with its group, but the backend still remembers creating it
+	mr.FastForward(EventTTLSeconds * time.Second)
+	if err := backend.Push(ctx, "ABCDEF", State, "2"); err != nil {
+		t.Fatal(err)
+	}
+	event, err := backend.Pop(ctx, "ABCDEF", time.Millisecond*10)
+	if err != nil {
+		t.Fatalf("expected the group to be recreated, got %v", err)
+	}
+	if string(event.Payload) != "2" {
+		t.Errorf("expected the event pushed after expiry, got %+v", event)
+	}
+}
+
+func TestStreamBackendReclaim(t *testing.T) {
+	ctx := context.Background()
+	client := newTestRedis(t)
+	crashed := NewStreamBackend(client, "muter", "crashed")
+	replacement := NewStreamBackend(client, "muter", "replacement")
+
+	if err := crashed.Push(ctx, "ABCDEF", State, "1"); err != nil {
+		t.Fatal(err)
+	}
+	lost, err := crashed.Pop(ctx, "ABCDEF", time.Millisecond*10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := replacement.Reclaim(ctx, "ABCDEF", time.Hour, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("events within minIdle should not be reclaimed, got %+v", events)
+	}
+
+	time.Sleep(time.Millisecond * 20)
+	events, err = replacement.Reclaim(ctx, "ABCDEF", time.Millisecond*10, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].ID != lost.ID {
+		t.Fatalf("expected the unacked event to be reclaimed, got %+v", events)
+	}
+	if err := replacement.Ack(ctx, "ABCDEF", events[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Millisecond * 20)
+	if events, err := replacement.Reclaim(ctx, "ABCDEF", time.Millisecond*10, 10); err != nil || len(events) != 0 {
+		t.Errorf("acked events should not be reclaimed, got %+v, %v", events, err)
+	}
+	if events, err := replacement.Reclaim(ctx, "UNUSED", 0, 10); err != nil || len(events) != 0 {
+		t.Errorf("expected nothing to reclaim without a group, got %+v, %v", events, err)
+	}
+}
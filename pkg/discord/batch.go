@@ -0,0 +1,92 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/das08/utils/pkg/premium"
+)
+
+// TierConcurrency is how many member PATCHes may be in flight at once for a guild of each premium tier
+var TierConcurrency = map[premium.Tier]int{
+	premium.FreeTier:     1,
+	premium.BronzeTier:   2,
+	premium.SilverTier:   3,
+	premium.GoldTier:     5,
+	premium.TrialTier:    5,
+	premium.SelfHostTier: 10,
+}
+
+func ConcurrencyForTier(tier premium.Tier) int {
+	if n, ok := TierConcurrency[tier]; ok {
+		return n
+	}
+	return 1
+}
+
+// ExecuteModifyRequest applies every UserModify in req using the official bot's client, running up to
+// ConcurrencyForTier(req.Premium) requests at once. Every user is attempted even if some fail; the returned error
+// describes the first failure.
+func ExecuteModifyRequest(ctx context.Context, client *MemberClient, guildID uint64, req UserModifyRequest) (MuteDeafenSuccessCounts, error) {
+	return fanOut(ctx, req, func(user UserModify) (MuteDeafenSuccessCounts, error) {
+		rateLimits, err := client.ModifyMember(ctx, guildID, user.UserID, user.Params())
+		counts := MuteDeafenSuccessCounts{RateLimit: rateLimits}
+		if err == nil {
+			counts.Official = 1
+		}
+		return counts, err
+	})
+}
+
+// fanOut runs modify for every user in req with the request's tier concurrency, summing the counts of each. Users
+// still waiting for a slot when ctx is cancelled are skipped and counted as failures.
+func fanOut(ctx context.Context, req UserModifyRequest, modify func(UserModify) (MuteDeafenSuccessCounts, error)) (MuteDeafenSuccessCounts, error) {
+	counts := MuteDeafenSuccessCounts{}
+	sem := make(chan struct{}, ConcurrencyForTier(req.Premium))
+	wg := sync.WaitGroup{}
+
+	lock := sync.Mutex{}
+	var firstErr error
+	failed := 0
+
+schedule:
+	for i, user := range req.Users {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			lock.Lock()
+			if firstErr == nil {
+				firstErr = fmt.Errorf("modifying user %d: %w", user.UserID, ctx.Err())
+			}
+			failed += len(req.Users) - i
+			lock.Unlock()
+			break schedule
+		}
+		wg.Add(1)
+		go func(user UserModify) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			c, err := modify(user)
+
+			lock.Lock()
+			defer lock.Unlock()
+			counts.Add(c)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("modifying user %d: %w", user.UserID, err)
+				}
+				failed++
+			}
+		}(user)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return counts, fmt.Errorf("%d of %d modifications failed, first: %w", failed, len(req.Users), firstErr)
+	}
+	return counts, nil
+}
@@ -0,0 +1,200 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/das08/utils/pkg/premium"
+)
+
+// fakeDiscord stands in for the member PATCH endpoint, rate limiting the first request for each rate-limited user
+type fakeDiscord struct {
+	lock        sync.Mutex
+	rateLimited map[string]bool
+	patched     map[string]PatchParams
+	tokens      map[string]string
+	url         string
+	inFlight    int64
+	maxInFlight int64
+}
+
+func newFakeDiscord(t *testing.T, rateLimitedUsers ...string) (*fakeDiscord, *MemberClient) {
+	fd := &fakeDiscord{
+		rateLimited: map[string]bool{},
+		patched:     map[string]PatchParams{},
+		tokens:      map[string]string{},
+	}
+	for _, u := range rateLimitedUsers {
+		fd.rateLimited[u] = true
+	}
+	server := httptest.NewServer(fd)
+	t.Cleanup(server.Close)
+	fd.url = server.URL + "/"
+
+	return fd, fd.client("token")
+}
+
+func (fd *fakeDiscord) client(token string) *MemberClient {
+	client := NewMemberClient(token)
+	client.BaseURL = fd.url
+	return client
+}
+
+func (fd *fakeDiscord) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := atomic.AddInt64(&fd.inFlight, 1)
+	defer atomic.AddInt64(&fd.inFlight, -1)
+	for {
+		max := atomic.LoadInt64(&fd.maxInFlight)
+		if n <= max || atomic.CompareAndSwapInt64(&fd.maxInFlight, max, n) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond * 5)
+
+	if r.Method != http.MethodPatch || !strings.HasPrefix(r.Header.Get("Authorization"), "Bot ") {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	userID := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+
+	fd.lock.Lock()
+	defer fd.lock.Unlock()
+	if fd.rateLimited[userID] {
+		fd.rateLimited[userID] = false
+		w.Header().Set("Retry-After", "0.01")
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	if userID == "0" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	p := PatchParams{}
+	_ = json.NewDecoder(r.Body).Decode(&p)
+	fd.patched[userID] = p
+	fd.tokens[userID] = strings.TrimPrefix(r.Header.Get("Authorization"), "Bot ")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestExecuteModifyRequest(t *testing.T) {
+	fd, client := newFakeDiscord(t, "2", "4")
+
+	req := UserModifyRequest{
+		Premium: premium.SilverTier,
+		Users: []UserModify{
+			{UserID: 1, Mute: true, Deaf: true},
+			{UserID: 2, Mute: true},
+			{UserID: 3},
+			{UserID: 4, Deaf: true},
+			{UserID: 5, Mute: true},
+			{UserID: 6, Mute: true},
+		},
+	}
+	counts, err := ExecuteModifyRequest(context.Background(), client, 1234, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts.Official != 6 {
+		t.Errorf("expected 6 official modifications, got %d", counts.Official)
+	}
+	if counts.RateLimit != 2 {
+		t.Errorf("expected 2 rate limits, got %d", counts.RateLimit)
+	}
+	if fd.maxInFlight > int64(ConcurrencyForTier(premium.SilverTier)) {
+		t.Errorf("expected at most %d concurrent requests, saw %d", ConcurrencyForTier(premium.SilverTier), fd.maxInFlight)
+	}
+	if p := fd.patched["1"]; !p.Mute || !p.Deaf {
+		t.Errorf("user 1 should have been muted and deafened, got %+v", p)
+	}
+	if p := fd.patched["4"]; p.Mute || !p.Deaf {
+		t.Errorf("user 4 should have only been deafened, got %+v", p)
+	}
+}
+
+func TestExecuteModifyRequestFailures(t *testing.T) {
+	_, client := newFakeDiscord(t)
+
+	req := UserModifyRequest{
+		Premium: premium.FreeTier,
+		Users:   []UserModify{{UserID: 0, Mute: true}, {UserID: 1, Mute: true}},
+	}
+	counts, err := ExecuteModifyRequest(context.Background(), client, 1234, req)
+	if err == nil {
+		t.Error("expected an error when discord rejects a modification")
+	}
+	if counts.Official != 1 {
+		t.Errorf("the other user should still have been modified, got %d", counts.Official)
+	}
+}
+
+func TestFanOutStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	req := UserModifyRequest{
+		Premium: premium.FreeTier,
+		Users:   []UserModify{{UserID: 1}, {UserID: 2}, {UserID: 3}},
+	}
+
+	var modified int64
+	counts, err := fanOut(ctx, req, func(user UserModify) (MuteDeafenSuccessCounts, error) {
+		atomic.AddInt64(&modified, 1)
+		// the only slot is held until after the cancel
+		cancel()
+		time.Sleep(time.Millisecond * 10)
+		return MuteDeafenSuccessCounts{Official: 1}, nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the cancellation to be reported, got %v", err)
+	}
+	if modified != 1 || counts.Official != 1 {
+		t.Errorf("expected users after the cancel to be skipped, modified %d", modified)
+	}
+}
+
+func TestModifyMemberGivesUp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"retry_after": 0.001, "global": false}`))
+	}))
+	defer server.Close()
+
+	client := NewMemberClient("token")
+	client.BaseURL = server.URL + "/"
+	client.MaxRetries = 2
+
+	rateLimits, err := client.ModifyMember(context.Background(), 1, 2, PatchParams{Mute: true})
+	if err != ErrRateLimited {
+		t.Errorf("expected ErrRateLimited, got %v", err)
+	}
+	if rateLimits != 3 {
+		t.Errorf("expected 3 rate limits, got %d", rateLimits)
+	}
+}
+
+func TestModifyMemberLongRetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := NewMemberClient("token")
+	client.BaseURL = server.URL + "/"
+
+	start := time.Now()
+	rateLimits, err := client.ModifyMember(context.Background(), 1, 2, PatchParams{Mute: true})
+	var rlErr *RateLimitError
+	if !errors.Is(err, ErrRateLimited) || !errors.As(err, &rlErr) || rlErr.RetryAfter != time.Minute {
+		t.Errorf("expected a RateLimitError for a minute, got %v", err)
+	}
+	if rateLimits != 1 || time.Since(start) > time.Second {
+		t.Errorf("expected to give up on the first 429 without waiting, got %d rate limits after %s", rateLimits, time.Since(start))
+	}
+}
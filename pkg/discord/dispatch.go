@@ -0,0 +1,134 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/das08/utils/pkg/lock"
+	"github.com/das08/utils/pkg/logging"
+	"github.com/das08/utils/pkg/rediskey"
+	"github.com/go-redis/redis/v8"
+)
+
+// DefaultTokenLockTTL is how long a worker token is reserved for a guild after it's used to modify a member there
+const DefaultTokenLockTTL = time.Second
+
+// WorkerToken is an extra bot token that can issue mutes on behalf of the official bot
+type WorkerToken struct {
+	Client *MemberClient
+	// Hashed identifies the token in Redis keys without storing the token itself
+	Hashed string
+}
+
+func NewWorkerToken(token string) WorkerToken {
+	return WorkerToken{
+		Client: NewMemberClient(token),
+		Hashed: string(rediskey.HashToken(token)),
+	}
+}
+
+// Dispatcher spreads modifications across a pool of worker tokens. Each use of a worker takes its
+// rediskey.GuildTokenLock for LockTTL, so no process can use the same token on the same guild faster than that, and a
+// worker that is rate limited sets its rediskey.TokenRateLimit for as long as Discord asked it to wait, so no process
+// uses it anywhere until then. When every worker is locked or limited (or a worker fails), the official bot makes the
+// change instead.
+type Dispatcher struct {
+	redis    *redis.Client
+	official *MemberClient
+	workers  []WorkerToken
+	LockTTL  time.Duration
+	Logger   logging.Logger
+
+	next uint32
+}
+
+func NewDispatcher(client *redis.Client, official *MemberClient, workers []WorkerToken) *Dispatcher {
+	return &Dispatcher{
+		redis:    client,
+		official: official,
+		workers:  workers,
+		LockTTL:  DefaultTokenLockTTL,
+		Logger:   logging.Default(),
+	}
+}
+
+func (d *Dispatcher) logf(format string, v ...interface{}) {
+	logging.Printf(d.Logger, format, v...)
+}
+
+// Dispatch applies a single ModifyTask, recording whether a worker or the official bot did it. A worker's failure is
+// logged, and also returned if the official bot fails too.
+func (d *Dispatcher) Dispatch(ctx context.Context, task ModifyTask) (MuteDeafenSuccessCounts, error) {
+	counts := MuteDeafenSuccessCounts{}
+
+	var workerErr error
+	if worker, ok := d.acquireWorker(ctx, task.GuildID); ok {
+		// workers don't wait out a 429 themselves; the official bot takes over while the worker's bucket is limited
+		rateLimits, retryAfter, err := worker.Client.modifyMember(ctx, task.GuildID, task.UserID, task.Parameters, 0)
+		counts.RateLimit += rateLimits
+		if err == nil {
+			counts.Worker++
+			return counts, nil
+		}
+		if retryAfter > 0 {
+			d.limitWorker(ctx, worker, retryAfter)
+		}
+		workerErr = fmt.Errorf("worker %s: %w", worker.Hashed, err)
+		d.logf("Falling back to the official bot for user %d in guild %d: %v", task.UserID, task.GuildID, workerErr)
+	}
+
+	rateLimits, err := d.official.ModifyMember(ctx, task.GuildID, task.UserID, task.Parameters)
+	counts.RateLimit += rateLimits
+	if err == nil {
+		counts.Official++
+	} else if workerErr != nil {
+		err = fmt.Errorf("%v, then official bot: %w", workerErr, err)
+	}
+	return counts, err
+}
+
+// DispatchRequest is ExecuteModifyRequest, but routed through the worker pool
+func (d *Dispatcher) DispatchRequest(ctx context.Context, guildID uint64, req UserModifyRequest) (MuteDeafenSuccessCounts, error) {
+	return fanOut(ctx, req, func(user UserModify) (MuteDeafenSuccessCounts, error) {
+		return d.Dispatch(ctx, ModifyTask{
+			GuildID:    guildID,
+			UserID:     user.UserID,
+			Parameters: user.Params(),
+		})
+	})
+}
+
+// acquireWorker returns the first worker that isn't rate limited and whose lock on the guild is free, starting from a
+// different worker each call so the load is spread evenly. Workers whose bucket or lock can't be checked are skipped.
+func (d *Dispatcher) acquireWorker(ctx context.Context, guildID uint64) (WorkerToken, bool) {
+	if len(d.workers) == 0 {
+		return WorkerToken{}, false
+	}
+	guild := strconv.FormatUint(guildID, 10)
+	// take the modulo before converting, since the counter wraps around and int may be 32 bits
+	start := int(atomic.AddUint32(&d.next, 1) % uint32(len(d.workers)))
+
+	for i := range d.workers {
+		worker := d.workers[(start+i)%len(d.workers)]
+		limited, err := d.redis.Exists(ctx, rediskey.TokenRateLimit(worker.Hashed)).Result()
+		if err != nil || limited > 0 {
+			continue
+		}
+		// never released; the lock expiring is what lets the token be used on this guild again
+		acquired, err := lock.ForGuildToken(d.redis, guild, worker.Hashed, d.LockTTL).TryAcquire(ctx)
+		if err == nil && acquired {
+			return worker, true
+		}
+	}
+	return WorkerToken{}, false
+}
+
+// limitWorker keeps every process from using a worker until its 429 has passed
+func (d *Dispatcher) limitWorker(ctx context.Context, worker WorkerToken, retryAfter time.Duration) {
+	if err := d.redis.Set(ctx, rediskey.TokenRateLimit(worker.Hashed), 1, retryAfter).Err(); err != nil {
+		d.logf("Failed to record the rate limit for worker %s: %v", worker.Hashed, err)
+	}
+}
@@ -0,0 +1,147 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/das08/utils/pkg/premium"
+	"github.com/das08/utils/pkg/rediskey"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestDispatcher(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	fd, official := newFakeDiscord(t)
+	var workers []WorkerToken
+	for _, token := range []string{"worker1", "worker2"} {
+		worker := NewWorkerToken(token)
+		worker.Client = fd.client(token)
+		workers = append(workers, worker)
+	}
+	d := NewDispatcher(client, official, workers)
+	d.LockTTL = time.Minute
+
+	req := UserModifyRequest{
+		Premium: premium.FreeTier,
+		Users:   []UserModify{{UserID: 1, Mute: true}, {UserID: 2, Mute: true}, {UserID: 3, Mute: true}},
+	}
+	counts, err := d.DispatchRequest(ctx, 1234, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts.Worker != 2 || counts.Official != 1 {
+		t.Errorf("expected 2 worker and 1 official modification, got %+v", counts)
+	}
+
+	used := map[string]bool{}
+	for _, token := range fd.tokens {
+		used[token] = true
+	}
+	for _, token := range []string{"worker1", "worker2", "token"} {
+		if !used[token] {
+			t.Errorf("expected %s to have been used", token)
+		}
+	}
+	for _, w := range workers {
+		if !mr.Exists(rediskey.GuildTokenLock("1234", w.Hashed)) {
+			t.Errorf("expected the guild lock for worker %s to be held", w.Hashed)
+		}
+	}
+
+	// the locks are per guild, so another guild can still use the workers
+	counts, err = d.Dispatch(ctx, ModifyTask{GuildID: 4321, UserID: 4, Parameters: PatchParams{Deaf: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts.Worker != 1 {
+		t.Errorf("expected a worker to be used for a different guild, got %+v", counts)
+	}
+}
+
+func TestDispatcherFallsBackOnWorkerFailure(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	fd, official := newFakeDiscord(t)
+	broken := NewWorkerToken("broken")
+	broken.Client.BaseURL = "http://127.0.0.1:0/"
+	d := NewDispatcher(client, official, []WorkerToken{broken})
+	logged := &bytes.Buffer{}
+	d.Logger = log.New(logged, "", 0)
+
+	counts, err := d.Dispatch(context.Background(), ModifyTask{GuildID: 1234, UserID: 1, Parameters: PatchParams{Mute: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts.Official != 1 || counts.Worker != 0 {
+		t.Errorf("expected the official bot to take over from a failed worker, got %+v", counts)
+	}
+	if fd.tokens["1"] != "token" {
+		t.Errorf("expected the official token to be used, got %s", fd.tokens["1"])
+	}
+	if !strings.Contains(logged.String(), broken.Hashed) {
+		t.Errorf("expected the worker's failure to be logged, got %q", logged.String())
+	}
+}
+
+func TestDispatcherRespectsWorkerRateLimit(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	fd, official := newFakeDiscord(t, "1")
+	worker := NewWorkerToken("worker")
+	worker.Client = fd.client("worker")
+	d := NewDispatcher(client, official, []WorkerToken{worker})
+	d.Logger = nil
+
+	// the worker's 429 isn't retried; the official bot takes over and the worker's bucket is limited
+	counts, err := d.Dispatch(ctx, ModifyTask{GuildID: 1, UserID: 1, Parameters: PatchParams{Mute: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts.Official != 1 || counts.RateLimit != 1 {
+		t.Errorf("expected the official bot to take over after a 429, got %+v", counts)
+	}
+	if !mr.Exists(rediskey.TokenRateLimit(worker.Hashed)) {
+		t.Fatal("expected the worker's rate limit to be recorded")
+	}
+
+	// limited on every guild, not just the one it was limited on
+	counts, _ = d.Dispatch(ctx, ModifyTask{GuildID: 2, UserID: 2, Parameters: PatchParams{Mute: true}})
+	if counts.Worker != 0 {
+		t.Errorf("expected a rate limited worker to be skipped, got %+v", counts)
+	}
+
+	mr.FastForward(time.Second)
+	counts, _ = d.Dispatch(ctx, ModifyTask{GuildID: 3, UserID: 3, Parameters: PatchParams{Mute: true}})
+	if counts.Worker != 1 {
+		t.Errorf("expected the worker to be used once its retry-after passed, got %+v", counts)
+	}
+}
+
+func TestAcquireWorkerAfterWrapAround(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	d := NewDispatcher(client, nil, []WorkerToken{{Hashed: "a"}, {Hashed: "b"}, {Hashed: "c"}})
+	d.next = math.MaxUint32 - 1
+	for i := 0; i < 4; i++ {
+		if _, ok := d.acquireWorker(context.Background(), uint64(i)); !ok {
+			t.Errorf("expected a worker on call %d", i)
+		}
+	}
+}
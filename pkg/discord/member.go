@@ -0,0 +1,144 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const DefaultMaxRetries = 3
+
+// MaxRetryAfter is the longest a single 429 can make us wait before retrying. Longer waits give up with a
+// RateLimitError instead, so a bogus header can't stall a mute indefinitely.
+const MaxRetryAfter = time.Second * 10
+
+var ErrRateLimited = errors.New("still rate limited after the maximum number of retries")
+
+// RateLimitError is returned when Discord asks for a longer wait than MaxRetryAfter. It matches ErrRateLimited.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited for %s, longer than the maximum of %s", e.RetryAfter, MaxRetryAfter)
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// MemberClient PATCHes guild members directly against the Discord REST API using a single bot token. Unlike
+// discordgo.Session, it reports every 429 it runs into, so callers can count rate limits.
+type MemberClient struct {
+	token string
+
+	// BaseURL is the root of the REST API, discordgo.EndpointAPI by default
+	BaseURL    string
+	HTTP       *http.Client
+	MaxRetries int
+}
+
+func NewMemberClient(token string) *MemberClient {
+	if !strings.HasPrefix(token, "Bot ") {
+		token = "Bot " + token
+	}
+	return &MemberClient{
+		token:      token,
+		BaseURL:    discordgo.EndpointAPI,
+		HTTP:       &http.Client{Timeout: time.Second * 10},
+		MaxRetries: DefaultMaxRetries,
+	}
+}
+
+// ModifyMember applies params to a guild member, retrying after any 429s. Returns how many times it was rate limited.
+func (c *MemberClient) ModifyMember(ctx context.Context, guildID, userID uint64, params PatchParams) (int64, error) {
+	rateLimits, _, err := c.modifyMember(ctx, guildID, userID, params, c.MaxRetries)
+	return rateLimits, err
+}
+
+// modifyMember is ModifyMember with a retry limit. When it gives up because of a 429, it also returns how long Discord
+// asked it to wait.
+func (c *MemberClient) modifyMember(ctx context.Context, guildID, userID uint64, params PatchParams, maxRetries int) (int64, time.Duration, error) {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return 0, 0, err
+	}
+	url := fmt.Sprintf("%sguilds/%d/members/%d", c.BaseURL, guildID, userID)
+
+	var rateLimits int64
+	for {
+		retryAfter, err := c.patch(ctx, url, body)
+		if err != nil || retryAfter == 0 {
+			return rateLimits, 0, err
+		}
+
+		rateLimits++
+		if retryAfter > MaxRetryAfter {
+			return rateLimits, retryAfter, &RateLimitError{RetryAfter: retryAfter}
+		}
+		if rateLimits > int64(maxRetries) {
+			return rateLimits, retryAfter, ErrRateLimited
+		}
+		select {
+		case <-ctx.Done():
+			return rateLimits, 0, ctx.Err()
+		case <-time.After(retryAfter):
+		}
+	}
+}
+
+// patch sends a single request, returning how long to wait before retrying if it was rate limited
+func (c *MemberClient) patch(ctx context.Context, url string, body []byte) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return retryAfter(resp), nil
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return 0, nil
+	default:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return 0, fmt.Errorf("discord returned %s: %s", resp.Status, msg)
+	}
+}
+
+// retryAfter reads the wait time from a 429, preferring the Retry-After header over the JSON body. Both are in seconds.
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64)
+	if err != nil {
+		rl := struct {
+			RetryAfter float64 `json:"retry_after"`
+		}{}
+		if json.NewDecoder(io.LimitReader(resp.Body, 512)).Decode(&rl) == nil {
+			seconds = rl.RetryAfter
+		}
+	}
+
+	wait := time.Duration(seconds * float64(time.Second))
+	if wait <= 0 {
+		// still have to back off from a 429, even if it didn't say for how long
+		return time.Millisecond * 100
+	}
+	return wait
+}
@@ -0,0 +1,74 @@
+package fuzzy
+
+import (
+	"sort"
+	"strings"
+)
+
+// Distance is the Levenshtein distance between a and b, counted in runes and ignoring case
+func Distance(a, b string) int {
+	ra := []rune(strings.ToLower(a))
+	rb := []rune(strings.ToLower(b))
+
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// MaxDistance is how many edits a suggestion may be from the input: about a third of its length, so short inputs
+// don't match everything
+func MaxDistance(input string) int {
+	n := len([]rune(input))/3 + 1
+	if n > 3 {
+		n = 3
+	}
+	return n
+}
+
+// Suggest returns the candidates within MaxDistance of the input, closest first
+func Suggest(input string, candidates []string) []string {
+	limit := MaxDistance(input)
+	type match struct {
+		value    string
+		distance int
+	}
+	var matches []match
+	for _, c := range candidates {
+		if d := Distance(input, c); d <= limit {
+			matches = append(matches, match{c, d})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].distance < matches[j].distance
+	})
+
+	suggestions := make([]string, len(matches))
+	for i, m := range matches {
+		suggestions[i] = m.value
+	}
+	return suggestions
+}
@@ -0,0 +1,36 @@
+package fuzzy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"lobby", "lobby", 0},
+		{"Lobby", "lobby", 0},
+		{"lobby", "loby", 1},
+		{"kitten", "sitting", 3},
+		{"", "abc", 3},
+		{"müde", "mude", 1},
+	}
+	for _, test := range tests {
+		if d := Distance(test.a, test.b); d != test.distance {
+			t.Errorf("Distance(%q, %q): expected %d, got %d", test.a, test.b, test.distance, d)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"leaderboardSize", "leaderboardMin", "language"}
+	if s := Suggest("leaderbordSize", candidates); !reflect.DeepEqual(s, []string{"leaderboardSize"}) {
+		t.Errorf("unexpected suggestions %v", s)
+	}
+	if s := Suggest("xyz", candidates); len(s) != 0 {
+		t.Errorf("expected no suggestions, got %v", s)
+	}
+}
@@ -1,5 +1,16 @@
 package game
 
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/das08/utils/pkg/fuzzy"
+	"github.com/das08/utils/pkg/locale"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
 // Color : Int constant mapping
 const (
 	Red    = 0
@@ -46,12 +57,7 @@ var ColorStrings = map[string]int{
 
 // GetColorStringForInt does what it sounds like
 func GetColorStringForInt(colorint int) string {
-	for str, idx := range ColorStrings {
-		if idx == colorint {
-			return str
-		}
-	}
-	return ""
+	return Color(colorint).Name()
 }
 
 // IsColorString determines if a string is actually one of our colors
@@ -59,3 +65,180 @@ func IsColorString(test string) bool {
 	_, ok := ColorStrings[test]
 	return ok
 }
+
+var ErrUnknownColor = errors.New("unknown color")
+
+// Color is a player's color, numbered like the constants above and in capture's payloads
+type Color int
+
+// NoColor is what ParseColor returns when it can't tell which color was meant
+const NoColor Color = -1
+
+type colorInfo struct {
+	name string
+	// body and shadow are the in-game RGB colors
+	body, shadow int
+	display      *i18n.Message
+}
+
+// colors is indexed by Color, so every lookup by color is constant time
+var colors = [...]colorInfo{
+	Red:    {"red", 0xC51111, 0x7A0838, &i18n.Message{ID: "color.red", Other: "Red"}},
+	Blue:   {"blue", 0x132ED1, 0x09158E, &i18n.Message{ID: "color.blue", Other: "Blue"}},
+	Green:  {"green", 0x117F2D, 0x0A4D2E, &i18n.Message{ID: "color.green", Other: "Green"}},
+	Pink:   {"pink", 0xED54BA, 0xAB2BAD, &i18n.Message{ID: "color.pink", Other: "Pink"}},
+	Orange: {"orange", 0xEF7D0D, 0xB33E15, &i18n.Message{ID: "color.orange", Other: "Orange"}},
+	Yellow: {"yellow", 0xF5F557, 0xC38823, &i18n.Message{ID: "color.yellow", Other: "Yellow"}},
+	Black:  {"black", 0x3F474E, 0x1E1F26, &i18n.Message{ID: "color.black", Other: "Black"}},
+	White:  {"white", 0xD6E0F0, 0x8394BF, &i18n.Message{ID: "color.white", Other: "White"}},
+	Purple: {"purple", 0x6B2FBB, 0x3B177C, &i18n.Message{ID: "color.purple", Other: "Purple"}},
+	Brown:  {"brown", 0x71491E, 0x5E2615, &i18n.Message{ID: "color.brown", Other: "Brown"}},
+	Cyan:   {"cyan", 0x38FEDC, 0x24A8BE, &i18n.Message{ID: "color.cyan", Other: "Cyan"}},
+	Lime:   {"lime", 0x50EF39, 0x15A742, &i18n.Message{ID: "color.lime", Other: "Lime"}},
+	Maroon: {"maroon", 0x6B2B3C, 0x41161F, &i18n.Message{ID: "color.maroon", Other: "Maroon"}},
+	Rose:   {"rose", 0xECC0D3, 0xDE92B3, &i18n.Message{ID: "color.rose", Other: "Rose"}},
+	Banana: {"banana", 0xFFFEBE, 0xD2BC89, &i18n.Message{ID: "color.banana", Other: "Banana"}},
+	Gray:   {"gray", 0x708496, 0x475565, &i18n.Message{ID: "color.gray", Other: "Gray"}},
+	Tan:    {"tan", 0x928776, 0x51413E, &i18n.Message{ID: "color.tan", Other: "Tan"}},
+	Coral:  {"coral", 0xEC7578, 0xB4434F, &i18n.Message{ID: "color.coral", Other: "Coral"}},
+}
+
+// Colors lists every color, in order
+var Colors = func() []Color {
+	all := make([]Color, len(colors))
+	for i := range colors {
+		all[i] = Color(i)
+	}
+	return all
+}()
+
+// colorAliases are other names people use for the colors, with spaces, dashes and underscores removed
+var colorAliases = map[string]Color{
+	"grey":        Gray,
+	"lightblue":   Cyan,
+	"teal":        Cyan,
+	"lightgreen":  Lime,
+	"darkgreen":   Green,
+	"darkblue":    Blue,
+	"darkred":     Maroon,
+	"burgundy":    Maroon,
+	"lightpink":   Rose,
+	"lightyellow": Banana,
+	"violet":      Purple,
+	"beige":       Tan,
+	"salmon":      Coral,
+}
+
+func (c Color) Valid() bool {
+	return c >= 0 && int(c) < len(colors)
+}
+
+// Name is the canonical lowercase name, as in ColorStrings, or "" for an invalid color
+func (c Color) Name() string {
+	if !c.Valid() {
+		return ""
+	}
+	return colors[c].name
+}
+
+func (c Color) String() string {
+	if !c.Valid() {
+		return fmt.Sprintf("Color(%d)", int(c))
+	}
+	return colors[c].name
+}
+
+// RGB is the body color, for embeds
+func (c Color) RGB() int {
+	return c.Body()
+}
+
+// Hex is the body color like #C51111
+func (c Color) Hex() string {
+	return fmt.Sprintf("#%06X", c.Body())
+}
+
+func (c Color) Body() int {
+	if !c.Valid() {
+		return 0
+	}
+	return colors[c].body
+}
+
+func (c Color) Shadow() int {
+	if !c.Valid() {
+		return 0
+	}
+	return colors[c].shadow
+}
+
+// EmojiName is the name of the crewmate emoji for the color, like aured and aureddead
+func (c Color) EmojiName(dead bool) string {
+	if !c.Valid() {
+		return ""
+	}
+	if dead {
+		return "au" + colors[c].name + "dead"
+	}
+	return "au" + colors[c].name
+}
+
+func (c Color) DisplayName(lang string) string {
+	if !c.Valid() {
+		return ""
+	}
+	return locale.LocalizeMessage(colors[c].display, lang)
+}
+
+func normalizeColor(s string) string {
+	return strings.NewReplacer(" ", "", "-", "", "_", "").Replace(strings.ToLower(strings.TrimSpace(s)))
+}
+
+// ParseColor reads a color's name, a common alias like grey or light blue, or a small typo of either, as long as
+// only one color is that close. Otherwise the error suggests what was probably meant.
+func ParseColor(input string) (Color, error) {
+	name := normalizeColor(input)
+	if c, ok := ColorStrings[name]; ok {
+		return Color(c), nil
+	}
+	if c, ok := colorAliases[name]; ok {
+		return c, nil
+	}
+
+	names := make([]string, 0, len(colors)+len(colorAliases))
+	for _, info := range colors {
+		names = append(names, info.name)
+	}
+	for alias := range colorAliases {
+		names = append(names, alias)
+	}
+	sort.Strings(names)
+	suggestions := fuzzy.Suggest(name, names)
+
+	// several names for the same color can be just as close, so compare the colors they mean
+	best := map[Color]bool{}
+	var closest Color
+	for i, s := range suggestions {
+		if i > 0 && fuzzy.Distance(name, s) > fuzzy.Distance(name, suggestions[0]) {
+			break
+		}
+		closest = colorNamed(s)
+		best[closest] = true
+	}
+	if len(best) == 1 {
+		return closest, nil
+	}
+
+	err := fmt.Errorf("%w: %q", ErrUnknownColor, input)
+	if len(suggestions) > 0 {
+		err = fmt.Errorf("%w, did you mean %s?", err, quoteAll(suggestions))
+	}
+	return NoColor, err
+}
+
+func colorNamed(name string) Color {
+	if c, ok := ColorStrings[name]; ok {
+		return Color(c)
+	}
+	return colorAliases[name]
+}
@@ -0,0 +1,83 @@
+package game
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+)
+
+func TestColorsMatchColorStrings(t *testing.T) {
+	if len(Colors) != len(ColorStrings) {
+		t.Fatalf("expected %d colors, got %d", len(ColorStrings), len(Colors))
+	}
+	for name, i := range ColorStrings {
+		if Color(i).Name() != name || GetColorStringForInt(i) != name {
+			t.Errorf("expected color %d to be %s, got %s", i, name, Color(i).Name())
+		}
+	}
+}
+
+func TestColorAttributes(t *testing.T) {
+	c := Color(Red)
+	if c.Hex() != "#C51111" || c.RGB() != 0xC51111 || c.Shadow() != 0x7A0838 {
+		t.Errorf("unexpected red: %s %x %x", c.Hex(), c.RGB(), c.Shadow())
+	}
+	if c.EmojiName(false) != "aured" || c.EmojiName(true) != "aureddead" {
+		t.Errorf("unexpected emoji names %s, %s", c.EmojiName(false), c.EmojiName(true))
+	}
+	if c.DisplayName("en") != "Red" {
+		t.Errorf("unexpected display name %s", c.DisplayName("en"))
+	}
+
+	invalid := Color(18)
+	if invalid.Valid() || invalid.Name() != "" || invalid.Hex() != "#000000" || NoColor.Valid() || GetColorStringForInt(-1) != "" {
+		t.Error("expected invalid colors to have no attributes")
+	}
+}
+
+func TestColorNamesAreTranslated(t *testing.T) {
+	var messages map[string]string
+	if _, err := toml.DecodeFile("../../locales/active.en.toml", &messages); err != nil {
+		t.Fatal(err)
+	}
+	for c, info := range colors {
+		if messages[info.display.ID] != info.display.Other {
+			t.Errorf("expected %s for color %d in active.en.toml to be %q, got %q", info.display.ID, c, info.display.Other, messages[info.display.ID])
+		}
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		input string
+		color Color
+		err   error
+	}{
+		{"red", Red, nil},
+		{" Banana ", Banana, nil},
+		{"grey", Gray, nil},
+		{"light blue", Cyan, nil},
+		{"Light-Blue", Cyan, nil},
+		{"purpel", Purple, nil},
+		{"oragne", Orange, nil},
+		{"gry", Gray, nil},
+		{"", NoColor, ErrUnknownColor},
+		{"chartreuse", NoColor, ErrUnknownColor},
+	}
+	for _, test := range tests {
+		c, err := ParseColor(test.input)
+		if c != test.color || !errors.Is(err, test.err) {
+			t.Errorf("ParseColor(%q): expected %s, %v, got %s, %v", test.input, test.color, test.err, c, err)
+		}
+	}
+}
+
+func TestParseColorAmbiguous(t *testing.T) {
+	// one edit from both tan and teal (cyan)
+	_, err := ParseColor("tean")
+	if !errors.Is(err, ErrUnknownColor) || !strings.Contains(err.Error(), `did you mean "tan" or "teal"`) {
+		t.Errorf("expected an ambiguous typo to be rejected with suggestions, got %v", err)
+	}
+}
@@ -1,9 +1,116 @@
 package game
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidDelay = errors.New("invalid delay")
+
+// MaxDelay is the longest delay ParseDelay accepts
+const MaxDelay = time.Second * 10
+
+// Delay is a time.Duration that's stored as a whole number of seconds when it is one, and as a duration string like
+// "1.5s" when it isn't
+type Delay time.Duration
+
+func Seconds(n int) Delay {
+	return Delay(time.Duration(n) * time.Second)
+}
+
+func (d Delay) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+// String is the whole number of seconds if there is one, and a duration string otherwise. ParseDelay reads both.
+func (d Delay) String() string {
+	if time.Duration(d)%time.Second == 0 {
+		return strconv.FormatInt(int64(time.Duration(d)/time.Second), 10)
+	}
+	return time.Duration(d).String()
+}
+
+// ParseDelay reads a duration like "500ms", or a number of seconds like "2" or "1.5", between 0 and MaxDelay
+func ParseDelay(s string) (Delay, error) {
+	d, err := parseDelay(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 || d > Delay(MaxDelay) {
+		return 0, fmt.Errorf("%w: %q must be between 0 and %s", ErrInvalidDelay, strings.TrimSpace(s), MaxDelay)
+	}
+	return d, nil
+}
+
+// parseDelay is ParseDelay without the bounds, only rejecting what doesn't fit in a Duration
+func parseDelay(s string) (Delay, error) {
+	s = strings.TrimSpace(s)
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		// checked as a float, so huge values are rejected before they can overflow a Duration
+		if math.IsNaN(secs) || math.Abs(secs) > float64(math.MaxInt64)/float64(time.Second) {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidDelay, s)
+		}
+		return Delay(secs * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidDelay, s)
+	}
+	return Delay(d), nil
+}
+
+func (d Delay) MarshalJSON() ([]byte, error) {
+	if time.Duration(d)%time.Second == 0 {
+		return []byte(d.String()), nil
+	}
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON leaves the delay unchanged for null, like encoding/json does for other types. Stored delays aren't
+// bounds checked, so one out of range value doesn't make the rest of the settings unreadable; the registry checks
+// new ones.
+func (d *Delay) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var s string
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	} else {
+		s = string(data)
+	}
+	parsed, err := parseDelay(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
 // GameDelays struct
 type GameDelays struct {
 	// maps from origin->new phases, with the integer number of seconds for the delay
 	Delays map[PhaseNameString]map[PhaseNameString]int `json:"delays"`
+	// DelaysMs holds the delays that aren't a whole number of seconds, in milliseconds. Delays keeps them rounded up, so
+	// versions that only know about Delays still wait at least as long.
+	DelaysMs map[PhaseNameString]map[PhaseNameString]int `json:"delaysMs,omitempty"`
+	// MapDelays overrides some of the delays on particular maps, like Airship's spawn selection
+	MapDelays map[PlayMap]map[PhaseNameString]map[PhaseNameString]int `json:"mapDelays,omitempty"`
+	// MapDelaysMs is DelaysMs for MapDelays
+	MapDelaysMs map[PlayMap]map[PhaseNameString]map[PhaseNameString]int `json:"mapDelaysMs,omitempty"`
+	// Stagger spaces out each player's voice change after the first by this much, and Jitter adds up to this much
+	// more at random, so big lobbies don't hit Discord's rate limits all at once
+	Stagger Delay `json:"stagger,omitempty"`
+	Jitter  Delay `json:"jitter,omitempty"`
 }
 
 func MakeDefaultDelays() GameDelays {
@@ -28,6 +135,191 @@ func MakeDefaultDelays() GameDelays {
 	}
 }
 
+// GetDelay is the delay in whole seconds. Sub-second delays are rounded up, so 750ms is 1; use GetDuration to get
+// them exactly.
 func (gd *GameDelays) GetDelay(origin, dest Phase) int {
-	return gd.Delays[PhaseNames[origin]][PhaseNames[dest]]
+	return ceilSeconds(gd.GetDuration(origin, dest))
+}
+
+func (gd *GameDelays) GetDuration(origin, dest Phase) time.Duration {
+	d, _ := lookupDelay(gd.Delays, gd.DelaysMs, origin, dest)
+	return d
+}
+
+// Lookup is GetDurationForMap for a phase change that actually happened, checked against PhaseTransitions. Duplicate
+// phases are ErrDuplicatePhase and impossible ones ErrInvalidTransition, instead of silently having no delay.
+func (gd *GameDelays) Lookup(origin, dest Phase, playMap PlayMap) (time.Duration, error) {
+	if origin == dest {
+		return 0, fmt.Errorf("%w: %s", ErrDuplicatePhase, origin.ToString())
+	}
+	if !ValidTransition(origin, dest) {
+		return 0, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, origin.ToString(), dest.ToString())
+	}
+	return gd.GetDurationForMap(origin, dest, playMap), nil
+}
+
+// GetDelayForMap is GetDelay on a particular map
+func (gd *GameDelays) GetDelayForMap(origin, dest Phase, playMap PlayMap) int {
+	return ceilSeconds(gd.GetDurationForMap(origin, dest, playMap))
+}
+
+// GetDurationForMap is the delay on a particular map, falling back to GetDuration if the map doesn't override it
+func (gd *GameDelays) GetDurationForMap(origin, dest Phase, playMap PlayMap) time.Duration {
+	if d, ok := lookupDelay(gd.MapDelays[playMap], gd.MapDelaysMs[playMap], origin, dest); ok {
+		return d
+	}
+	return gd.GetDuration(origin, dest)
+}
+
+// SetDelay sets the delay to a whole number of seconds
+func (gd *GameDelays) SetDelay(origin, dest Phase, v int) {
+	gd.SetDuration(origin, dest, time.Duration(v)*time.Second)
+}
+
+// SetDuration sets the delay, rounded up to the millisecond
+func (gd *GameDelays) SetDuration(origin, dest Phase, d time.Duration) {
+	gd.Delays, gd.DelaysMs = setDelay(gd.Delays, gd.DelaysMs, origin, dest, d)
+}
+
+func (gd *GameDelays) SetDelayForMap(origin, dest Phase, playMap PlayMap, v int) {
+	gd.SetDurationForMap(origin, dest, playMap, time.Duration(v)*time.Second)
+}
+
+func (gd *GameDelays) SetDurationForMap(origin, dest Phase, playMap PlayMap, d time.Duration) {
+	if gd.MapDelays == nil {
+		gd.MapDelays = map[PlayMap]map[PhaseNameString]map[PhaseNameString]int{}
+	}
+	secs, ms := setDelay(gd.MapDelays[playMap], gd.MapDelaysMs[playMap], origin, dest, d)
+	gd.MapDelays[playMap] = secs
+	if ms != nil {
+		if gd.MapDelaysMs == nil {
+			gd.MapDelaysMs = map[PlayMap]map[PhaseNameString]map[PhaseNameString]int{}
+		}
+		gd.MapDelaysMs[playMap] = ms
+	}
+}
+
+// jitterRand is seeded per process, unlike the global source, so different processes don't jitter in lockstep
+var jitterRand = &lockedRand{rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// lockedRand makes a rand.Rand safe to share, like the global source is
+type lockedRand struct {
+	lock sync.Mutex
+	rand *rand.Rand
+}
+
+func (r *lockedRand) Int63n(n int64) int64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.rand.Int63n(n)
+}
+
+// ScheduledChange is when one player's voice state should change
+type ScheduledChange struct {
+	UserID string
+	FireAt time.Time
+}
+
+// Schedule says when each player's voice state should change for a phase change at now. Players are staggered in the
+// order given, after the delay for the map.
+func (gd *GameDelays) Schedule(origin, dest Phase, playMap PlayMap, userIDs []string, now time.Time) []ScheduledChange {
+	start := now.Add(gd.GetDurationForMap(origin, dest, playMap))
+	changes := make([]ScheduledChange, len(userIDs))
+	for i, userID := range userIDs {
+		offset := time.Duration(i) * gd.Stagger.Duration()
+		if gd.Jitter > 0 {
+			offset += time.Duration(jitterRand.Int63n(int64(gd.Jitter) + 1))
+		}
+		changes[i] = ScheduledChange{UserID: userID, FireAt: start.Add(offset)}
+	}
+	return changes
+}
+
+// ClearMapDelays drops a map's overrides, so it uses the same delays as every other map
+func (gd *GameDelays) ClearMapDelays(playMap PlayMap) {
+	delete(gd.MapDelays, playMap)
+	delete(gd.MapDelaysMs, playMap)
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// lookupDelay prefers the milliseconds, as long as the seconds are still what they round up to. Otherwise the seconds
+// were changed by a version that doesn't know about the milliseconds, and they win.
+func lookupDelay(secs, ms map[PhaseNameString]map[PhaseNameString]int, origin, dest Phase) (time.Duration, bool) {
+	s, ok := secs[origin.ToString()][dest.ToString()]
+	if !ok {
+		return 0, false
+	}
+	if m, ok := ms[origin.ToString()][dest.ToString()]; ok {
+		if d := time.Duration(m) * time.Millisecond; ceilSeconds(d) == s {
+			return d, true
+		}
+	}
+	return time.Duration(s) * time.Second, true
+}
+
+func setDelay(secs, ms map[PhaseNameString]map[PhaseNameString]int, origin, dest Phase, d time.Duration) (map[PhaseNameString]map[PhaseNameString]int, map[PhaseNameString]map[PhaseNameString]int) {
+	d = (d + time.Millisecond - 1) / time.Millisecond * time.Millisecond
+	secs = setDelayValue(secs, origin, dest, ceilSeconds(d))
+	if d%time.Second != 0 {
+		ms = setDelayValue(ms, origin, dest, int(d/time.Millisecond))
+	} else if dests := ms[origin.ToString()]; dests != nil {
+		delete(dests, dest.ToString())
+		if len(dests) == 0 {
+			delete(ms, origin.ToString())
+		}
+	}
+	return secs, ms
+}
+
+func setDelayValue(delays map[PhaseNameString]map[PhaseNameString]int, origin, dest Phase, v int) map[PhaseNameString]map[PhaseNameString]int {
+	if delays == nil {
+		delays = map[PhaseNameString]map[PhaseNameString]int{}
+	}
+	dests := delays[origin.ToString()]
+	if dests == nil {
+		dests = map[PhaseNameString]int{}
+		delays[origin.ToString()] = dests
+	}
+	dests[dest.ToString()] = v
+	return delays
+}
+
+func (gd *GameDelays) Clone() GameDelays {
+	return GameDelays{
+		Delays:      cloneDelays(gd.Delays),
+		DelaysMs:    cloneDelays(gd.DelaysMs),
+		MapDelays:   cloneMapDelays(gd.MapDelays),
+		MapDelaysMs: cloneMapDelays(gd.MapDelaysMs),
+		Stagger:     gd.Stagger,
+		Jitter:      gd.Jitter,
+	}
+}
+
+func cloneMapDelays(delays map[PlayMap]map[PhaseNameString]map[PhaseNameString]int) map[PlayMap]map[PhaseNameString]map[PhaseNameString]int {
+	if delays == nil {
+		return nil
+	}
+	c := make(map[PlayMap]map[PhaseNameString]map[PhaseNameString]int, len(delays))
+	for playMap, d := range delays {
+		c[playMap] = cloneDelays(d)
+	}
+	return c
+}
+
+func cloneDelays(delays map[PhaseNameString]map[PhaseNameString]int) map[PhaseNameString]map[PhaseNameString]int {
+	if delays == nil {
+		return nil
+	}
+	c := make(map[PhaseNameString]map[PhaseNameString]int, len(delays))
+	for origin, dests := range delays {
+		inner := make(map[PhaseNameString]int, len(dests))
+		for dest, v := range dests {
+			inner[dest] = v
+		}
+		c[origin] = inner
+	}
+	return c
 }
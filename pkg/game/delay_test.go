@@ -0,0 +1,241 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGetDelayForMap(t *testing.T) {
+	delays := MakeDefaultDelays()
+	delays.SetDelayForMap(LOBBY, TASKS, AIRSHIP, 10)
+
+	tests := []struct {
+		name        string
+		origin, dst Phase
+		playMap     PlayMap
+		expected    int
+	}{
+		{"override", LOBBY, TASKS, AIRSHIP, 10},
+		{"transition without an override", DISCUSS, TASKS, AIRSHIP, 7},
+		{"map without overrides", LOBBY, TASKS, SKELD, 7},
+	}
+	for _, test := range tests {
+		if d := delays.GetDelayForMap(test.origin, test.dst, test.playMap); d != test.expected {
+			t.Errorf("%s: expected %d, got %d", test.name, test.expected, d)
+		}
+	}
+
+	delays.ClearMapDelays(AIRSHIP)
+	if d := delays.GetDelayForMap(LOBBY, TASKS, AIRSHIP); d != 7 {
+		t.Errorf("expected the default once the override is cleared, got %d", d)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	delays := MakeDefaultDelays()
+	delays.SetDurationForMap(DISCUSS, TASKS, AIRSHIP, time.Second*9)
+
+	tests := []struct {
+		origin, dest Phase
+		playMap      PlayMap
+		delay        time.Duration
+		err          error
+	}{
+		{LOBBY, TASKS, SKELD, time.Second * 7, nil},
+		{DISCUSS, TASKS, AIRSHIP, time.Second * 9, nil},
+		{TASKS, GAMEOVER, SKELD, 0, nil},
+		{DISCUSS, DISCUSS, SKELD, 0, ErrDuplicatePhase},
+		{LOBBY, DISCUSS, SKELD, 0, ErrInvalidTransition},
+		{MENU, TASKS, SKELD, 0, ErrInvalidTransition},
+	}
+	for _, test := range tests {
+		d, err := delays.Lookup(test.origin, test.dest, test.playMap)
+		if d != test.delay || !errors.Is(err, test.err) {
+			t.Errorf("Lookup(%d, %d): expected %s, %v, got %s, %v", test.origin, test.dest, test.delay, test.err, d, err)
+		}
+	}
+}
+
+func TestGameDelaysJSON(t *testing.T) {
+	data, err := json.Marshal(MakeDefaultDelays())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m["mapDelays"]; ok {
+		t.Error("expected delays without map overrides to keep their original JSON")
+	}
+
+	delays := MakeDefaultDelays()
+	delays.SetDelayForMap(TASKS, DISCUSS, POLUS, 3)
+	data, err = json.Marshal(delays)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded GameDelays
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if d := decoded.GetDelayForMap(TASKS, DISCUSS, POLUS); d != 3 {
+		t.Errorf("expected the override to survive JSON, got %d from %s", d, data)
+	}
+}
+
+func TestDelayJSON(t *testing.T) {
+	tests := []struct {
+		json  string
+		delay Delay
+	}{
+		{`7`, Seconds(7)},
+		{`0`, 0},
+		{`"1.5s"`, Delay(time.Millisecond * 1500)},
+		{`"250ms"`, Delay(time.Millisecond * 250)},
+	}
+	for _, test := range tests {
+		var d Delay
+		if err := json.Unmarshal([]byte(test.json), &d); err != nil {
+			t.Errorf("%s: %v", test.json, err)
+			continue
+		}
+		if d != test.delay {
+			t.Errorf("%s: expected %s, got %s", test.json, test.delay.Duration(), d.Duration())
+		}
+		data, err := json.Marshal(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != test.json {
+			t.Errorf("expected %s to marshal as %s, got %s", test.delay.Duration(), test.json, data)
+		}
+	}
+
+	// old readers and hand-edited documents may have fractional seconds as numbers
+	var d Delay
+	if err := json.Unmarshal([]byte(`0.5`), &d); err != nil || d != Delay(time.Millisecond*500) {
+		t.Errorf("expected 0.5 to be half a second, got %s (%v)", d.Duration(), err)
+	}
+	if err := json.Unmarshal([]byte(`"soon"`), &d); !errors.Is(err, ErrInvalidDelay) {
+		t.Errorf("expected ErrInvalidDelay, got %v", err)
+	}
+	// stored delays outside ParseDelay's bounds still decode, so they can't break the rest of the settings
+	if err := json.Unmarshal([]byte(`30`), &d); err != nil || d != Seconds(30) {
+		t.Errorf("expected an out of range delay to decode, got %s (%v)", d.Duration(), err)
+	}
+	if err := json.Unmarshal([]byte(`1e300`), &d); !errors.Is(err, ErrInvalidDelay) {
+		t.Errorf("expected ErrInvalidDelay for an overflowing delay, got %v", err)
+	}
+	d = Delay(time.Millisecond * 500)
+	if err := json.Unmarshal([]byte(`null`), &d); err != nil || d != Delay(time.Millisecond*500) {
+		t.Errorf("expected null to leave the delay alone, got %s (%v)", d.Duration(), err)
+	}
+}
+
+func TestGetDelayRoundsUp(t *testing.T) {
+	delays := MakeDefaultDelays()
+	delays.SetDuration(DISCUSS, TASKS, time.Millisecond*750)
+	if d := delays.GetDelay(DISCUSS, TASKS); d != 1 {
+		t.Errorf("expected 1, got %d", d)
+	}
+	if d := delays.GetDuration(DISCUSS, TASKS); d != time.Millisecond*750 {
+		t.Errorf("expected 750ms, got %s", d)
+	}
+
+	delays.SetDelay(DISCUSS, TASKS, 2)
+	if d := delays.GetDuration(DISCUSS, TASKS); d != time.Second*2 || len(delays.DelaysMs) != 0 {
+		t.Errorf("expected whole seconds to clear the milliseconds, got %s and %v", d, delays.DelaysMs)
+	}
+}
+
+func TestSubSecondDelaysJSON(t *testing.T) {
+	delays := MakeDefaultDelays()
+	delays.SetDuration(TASKS, LOBBY, time.Millisecond*250)
+	delays.SetDurationForMap(LOBBY, TASKS, AIRSHIP, time.Millisecond*1500)
+	data, err := json.Marshal(delays)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// what versions without the millisecond fields read
+	var old struct {
+		Delays    map[PhaseNameString]map[PhaseNameString]int             `json:"delays"`
+		MapDelays map[PlayMap]map[PhaseNameString]map[PhaseNameString]int `json:"mapDelays"`
+	}
+	if err := json.Unmarshal(data, &old); err != nil {
+		t.Fatal(err)
+	}
+	if old.Delays["TASKS"]["LOBBY"] != 1 || old.MapDelays[AIRSHIP]["LOBBY"]["TASKS"] != 2 {
+		t.Errorf("expected the seconds to be rounded up for older versions, got %s", data)
+	}
+
+	var decoded GameDelays
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if d := decoded.GetDuration(TASKS, LOBBY); d != time.Millisecond*250 {
+		t.Errorf("expected 250ms, got %s", d)
+	}
+	if d := decoded.GetDurationForMap(LOBBY, TASKS, AIRSHIP); d != time.Millisecond*1500 {
+		t.Errorf("expected 1.5s on Airship, got %s", d)
+	}
+
+	// an older version changing the seconds wins over the stale milliseconds
+	decoded.Delays["TASKS"]["LOBBY"] = 3
+	if d := decoded.GetDuration(TASKS, LOBBY); d != time.Second*3 {
+		t.Errorf("expected 3s, got %s", d)
+	}
+}
+
+func TestParseDelay(t *testing.T) {
+	tests := []struct {
+		input string
+		delay Delay
+		err   error
+	}{
+		{"2", Seconds(2), nil},
+		{" 1.5 ", Delay(time.Millisecond * 1500), nil},
+		{"500ms", Delay(time.Millisecond * 500), nil},
+		{"10s", Seconds(10), nil},
+		{"-1", 0, ErrInvalidDelay},
+		{"-500ms", 0, ErrInvalidDelay},
+		{"11", 0, ErrInvalidDelay},
+		{"1m", 0, ErrInvalidDelay},
+		{"1e300", 0, ErrInvalidDelay},
+		{"9999999999h", 0, ErrInvalidDelay},
+		{"NaN", 0, ErrInvalidDelay},
+	}
+	for _, test := range tests {
+		d, err := ParseDelay(test.input)
+		if d != test.delay || !errors.Is(err, test.err) {
+			t.Errorf("ParseDelay(%q): expected %s, %v, got %s, %v", test.input, test.delay.Duration(), test.err, d.Duration(), err)
+		}
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	delays := MakeDefaultDelays()
+	delays.SetDurationForMap(DISCUSS, TASKS, AIRSHIP, time.Second*2)
+	delays.Stagger = Delay(time.Millisecond * 100)
+
+	changes := delays.Schedule(DISCUSS, TASKS, AIRSHIP, []string{"a", "b", "c"}, now)
+	for i, c := range changes {
+		expected := now.Add(time.Second*2 + time.Duration(i)*time.Millisecond*100)
+		if c.UserID != []string{"a", "b", "c"}[i] || !c.FireAt.Equal(expected) {
+			t.Errorf("change %d: expected %s at %s, got %s at %s", i, []string{"a", "b", "c"}[i], expected, c.UserID, c.FireAt)
+		}
+	}
+
+	delays.Stagger = 0
+	delays.Jitter = Delay(time.Millisecond * 50)
+	for _, c := range delays.Schedule(LOBBY, TASKS, SKELD, []string{"a", "b", "c", "d"}, now) {
+		offset := c.FireAt.Sub(now) - time.Second*7
+		if offset < 0 || offset > time.Millisecond*50 {
+			t.Errorf("expected the jitter to be at most 50ms, got %s", offset)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrPayloadShape = errors.New("payload does not have the expected shape")
+
+// payloadKinds names the payloads carried by jobs and capture events, in the order both number their types
+var payloadKinds = []string{"connection", "lobby", "state", "player", "gameover"}
+
+// PayloadKind is the name of the i'th kind of payload, for JobType and EventType to print themselves with
+func PayloadKind(i int) string {
+	if i >= 0 && i < len(payloadKinds) {
+		return payloadKinds[i]
+	}
+	return fmt.Sprintf("unknown(%d)", i)
+}
+
+// MarshalPayload encodes a Lobby, Player or Gameover as a job or event payload
+func MarshalPayload(v interface{}) (string, error) {
+	payload, err := json.Marshal(v)
+	return string(payload), err
+}
+
+// requireKeys checks that a JSON object has the fields that identify what kind of payload it is, so a Player payload
+// can't be silently decoded as an empty Lobby (json.Unmarshal ignores fields it doesn't know about)
+func requireKeys(data []byte, name string, keys ...string) error {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return fmt.Errorf("%w: %s payload is not a JSON object: %v", ErrPayloadShape, name, err)
+	}
+	var missing []string
+	for _, k := range keys {
+		if _, ok := obj[k]; !ok {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s payload is missing %s", ErrPayloadShape, name, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func UnmarshalLobby(data []byte) (Lobby, error) {
+	lobby := Lobby{}
+	if err := requireKeys(data, "lobby", "LobbyCode", "Region", "Map"); err != nil {
+		return lobby, err
+	}
+	err := json.Unmarshal(data, &lobby)
+	return lobby, err
+}
+
+func UnmarshalPlayer(data []byte) (Player, error) {
+	player := Player{}
+	if err := requireKeys(data, "player", "Action", "Name", "Color"); err != nil {
+		return player, err
+	}
+	err := json.Unmarshal(data, &player)
+	return player, err
+}
+
+func UnmarshalGameover(data []byte) (Gameover, error) {
+	gameover := Gameover{}
+	if err := requireKeys(data, "gameover", "GameOverReason", "PlayerInfos"); err != nil {
+		return gameover, err
+	}
+	err := json.Unmarshal(data, &gameover)
+	return gameover, err
+}
+
+// UnmarshalPhase parses a state payload, which capture sends as the bare phase integer
+func UnmarshalPhase(data []byte) (Phase, error) {
+	num, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return UNINITIALIZED, fmt.Errorf("%w: phase payload %q is not an integer", ErrPayloadShape, data)
+	}
+	if num < int(LOBBY) || num > int(UNINITIALIZED) {
+		return UNINITIALIZED, fmt.Errorf("%w: phase %d is out of range", ErrPayloadShape, num)
+	}
+	return Phase(num), nil
+}
+
+// UnmarshalConnection parses a connection payload, which is "true" when the capture client connected
+func UnmarshalConnection(data []byte) (bool, error) {
+	connected, err := strconv.ParseBool(strings.TrimSpace(string(data)))
+	if err != nil {
+		return false, fmt.Errorf("%w: connection payload %q is not a boolean", ErrPayloadShape, data)
+	}
+	return connected, nil
+}
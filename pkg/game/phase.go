@@ -17,12 +17,15 @@ const (
 
 type PhaseNameString string
 
-// PhaseNames for lowercase, possibly for translation if needed
+// PhaseNames for lowercase, possibly for translation if needed. GAMEOVER and UNINITIALIZED used to be missing, so their
+// ToString was "" until the phase machine needed names for them.
 var PhaseNames = map[Phase]PhaseNameString{
-	LOBBY:   "LOBBY",
-	TASKS:   "TASKS",
-	DISCUSS: "DISCUSSION",
-	MENU:    "MENU",
+	LOBBY:         "LOBBY",
+	TASKS:         "TASKS",
+	DISCUSS:       "DISCUSSION",
+	MENU:          "MENU",
+	GAMEOVER:      "GAMEOVER",
+	UNINITIALIZED: "UNINITIALIZED",
 }
 
 // ToString for a Phase
@@ -30,35 +33,11 @@ func (phase *Phase) ToString() PhaseNameString {
 	return PhaseNames[*phase]
 }
 
+// GetPhaseFromString only understands the English aliases, exactly as typed apart from case. ParsePhase understands
+// other languages too, ignores surrounding spaces, and explains what's wrong with input it can't read.
 func GetPhaseFromString(input string) Phase {
-	if len(input) == 0 {
-		return UNINITIALIZED
-	}
-
-	switch strings.ToLower(input) {
-	case "lobby":
-		fallthrough
-	case "l":
-		return LOBBY
-	case "task":
-		fallthrough
-	case "t":
-		fallthrough
-	case "tasks":
-		fallthrough
-	case "game":
-		fallthrough
-	case "g":
-		return TASKS
-	case "discuss":
-		fallthrough
-	case "disc":
-		fallthrough
-	case "d":
-		fallthrough
-	case "discussion":
-		return DISCUSS
-	default:
-		return UNINITIALIZED
+	if phase, ok := defaultPhase(strings.ToLower(input)); ok {
+		return phase
 	}
+	return UNINITIALIZED
 }
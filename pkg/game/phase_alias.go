@@ -0,0 +1,152 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/das08/utils/pkg/fuzzy"
+	"github.com/das08/utils/pkg/locale"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+var ErrUnknownPhase = errors.New("unknown phase")
+
+// defaultPhaseAliases are the English names GetPhaseFromString has always understood. They work in every language.
+var defaultPhaseAliases = map[Phase][]string{
+	LOBBY:   {"lobby", "l"},
+	TASKS:   {"tasks", "task", "t", "game", "g"},
+	DISCUSS: {"discussion", "discuss", "disc", "d"},
+}
+
+// phaseAliasMessages are where each language's aliases are in the locale bundle, as a comma-separated list
+var phaseAliasMessages = map[Phase]*i18n.Message{
+	LOBBY:   {ID: "phase.aliases.LOBBY", Other: "lobby, l"},
+	TASKS:   {ID: "phase.aliases.TASKS", Other: "tasks, task, t, game, g"},
+	DISCUSS: {ID: "phase.aliases.DISCUSSION", Other: "discussion, discuss, disc, d"},
+}
+
+var phaseAliases = struct {
+	lock   sync.RWMutex
+	byLang map[string]map[string]Phase
+	// loaded is the languages whose aliases have been read from a locale bundle, which RegisterPhaseAliases doesn't do
+	loaded map[string]bool
+}{byLang: map[string]map[string]Phase{}, loaded: map[string]bool{}}
+
+// RegisterPhaseAliases adds names a phase can be typed as in a language
+func RegisterPhaseAliases(lang string, phase Phase, aliases ...string) {
+	phaseAliases.lock.Lock()
+	defer phaseAliases.lock.Unlock()
+	registerPhaseAliases(lang, phase, aliases)
+}
+
+func registerPhaseAliases(lang string, phase Phase, aliases []string) {
+	m := phaseAliases.byLang[lang]
+	if m == nil {
+		m = map[string]Phase{}
+		phaseAliases.byLang[lang] = m
+	}
+	for _, alias := range aliases {
+		if alias = normalizePhaseAlias(alias); alias != "" {
+			m[alias] = phase
+		}
+	}
+}
+
+// LoadPhaseAliases registers a language's aliases from the bundle's phase.aliases messages. Languages without their
+// own translations get the bundle's default language's aliases.
+func LoadPhaseAliases(bundle *i18n.Bundle, lang string) {
+	localizer := i18n.NewLocalizer(bundle, lang)
+
+	phaseAliases.lock.Lock()
+	defer phaseAliases.lock.Unlock()
+	phaseAliases.loaded[lang] = true
+	for phase, msg := range phaseAliasMessages {
+		list, err := localizer.Localize(&i18n.LocalizeConfig{DefaultMessage: msg})
+		if err != nil {
+			continue
+		}
+		registerPhaseAliases(lang, phase, strings.Split(list, ","))
+	}
+}
+
+func normalizePhaseAlias(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// ensurePhaseAliases loads a language's aliases from the locale bundle the first time it's used
+func ensurePhaseAliases(lang string) {
+	phaseAliases.lock.RLock()
+	ok := phaseAliases.loaded[lang]
+	phaseAliases.lock.RUnlock()
+	if !ok {
+		LoadPhaseAliases(locale.GetBundle(), lang)
+	}
+}
+
+func defaultPhase(alias string) (Phase, bool) {
+	for phase, aliases := range defaultPhaseAliases {
+		for _, a := range aliases {
+			if a == alias {
+				return phase, true
+			}
+		}
+	}
+	return UNINITIALIZED, false
+}
+
+// ParsePhase reads a phase typed in a language, or in English. Unknown input gets an error suggesting the closest
+// aliases.
+func ParsePhase(input, lang string) (Phase, error) {
+	alias := normalizePhaseAlias(input)
+	if alias == "" {
+		return UNINITIALIZED, fmt.Errorf("%w: no phase given", ErrUnknownPhase)
+	}
+	if phase, ok := defaultPhase(alias); ok {
+		return phase, nil
+	}
+
+	ensurePhaseAliases(lang)
+	phaseAliases.lock.RLock()
+	defer phaseAliases.lock.RUnlock()
+	localized := phaseAliases.byLang[lang]
+	if phase, ok := localized[alias]; ok {
+		return phase, nil
+	}
+
+	// only suggest whole names; single letters are within a typo of everything
+	candidates := map[string]bool{}
+	for a := range localized {
+		if len([]rune(a)) > 2 {
+			candidates[a] = true
+		}
+	}
+	for _, aliases := range defaultPhaseAliases {
+		for _, a := range aliases {
+			if len(a) > 2 {
+				candidates[a] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(candidates))
+	for a := range candidates {
+		names = append(names, a)
+	}
+	sort.Strings(names)
+
+	err := fmt.Errorf("%w: %q", ErrUnknownPhase, input)
+	if suggestions := fuzzy.Suggest(alias, names); len(suggestions) > 0 {
+		err = fmt.Errorf("%w, did you mean %s?", err, quoteAll(suggestions))
+	}
+	return UNINITIALIZED, err
+}
+
+func quoteAll(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+	return strings.Join(quoted, " or ")
+}
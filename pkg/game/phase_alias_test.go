@@ -0,0 +1,92 @@
+package game
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"golang.org/x/text/language"
+)
+
+func TestParsePhase(t *testing.T) {
+	bundle := i18n.NewBundle(language.English)
+	_ = bundle.AddMessages(language.Russian,
+		&i18n.Message{ID: "phase.aliases.LOBBY", Other: "лобби, л"},
+		&i18n.Message{ID: "phase.aliases.DISCUSSION", Other: "обсуждение, о"},
+	)
+	LoadPhaseAliases(bundle, "ru")
+	RegisterPhaseAliases("ru", TASKS, "Игра")
+
+	tests := []struct {
+		input, lang string
+		phase       Phase
+		err         error
+	}{
+		{"lobby", "ru", LOBBY, nil},
+		{"D", "en", DISCUSS, nil},
+		{"лобби", "ru", LOBBY, nil},
+		{" Обсуждение ", "ru", DISCUSS, nil},
+		{"игра", "ru", TASKS, nil},
+		{"лобби", "en", UNINITIALIZED, ErrUnknownPhase},
+		{"", "en", UNINITIALIZED, ErrUnknownPhase},
+		{"menu", "en", UNINITIALIZED, ErrUnknownPhase},
+	}
+	for _, test := range tests {
+		phase, err := ParsePhase(test.input, test.lang)
+		if phase != test.phase || !errors.Is(err, test.err) {
+			t.Errorf("ParsePhase(%q, %s): expected %d, %v, got %d, %v", test.input, test.lang, test.phase, test.err, phase, err)
+		}
+	}
+}
+
+func TestParsePhaseSuggests(t *testing.T) {
+	RegisterPhaseAliases("ru", LOBBY, "лобби")
+
+	_, err := ParsePhase("discusion", "en")
+	if err == nil || !strings.Contains(err.Error(), `"discussion"`) {
+		t.Errorf("expected a suggestion, got %v", err)
+	}
+	_, err = ParsePhase("лоби", "ru")
+	if err == nil || !strings.Contains(err.Error(), `"лобби"`) {
+		t.Errorf("expected a localized suggestion, got %v", err)
+	}
+}
+
+func TestRegisterBeforeLoad(t *testing.T) {
+	RegisterPhaseAliases("de", LOBBY, "warteraum")
+	// de has no translations, so the bundle's English aliases are loaded alongside the registered one
+	if phase, err := ParsePhase("warteraum", "de"); phase != LOBBY || err != nil {
+		t.Errorf("expected the registered alias, got %d, %v", phase, err)
+	}
+	phaseAliases.lock.RLock()
+	defer phaseAliases.lock.RUnlock()
+	if !phaseAliases.loaded["de"] || phaseAliases.byLang["de"]["game"] != TASKS {
+		t.Errorf("expected the bundle's aliases to be loaded after registering custom ones, got %v", phaseAliases.byLang["de"])
+	}
+}
+
+func TestPhaseAliasesAreTranslated(t *testing.T) {
+	var messages map[string]string
+	if _, err := toml.DecodeFile("../../locales/active.en.toml", &messages); err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range phaseAliasMessages {
+		if messages[msg.ID] != msg.Other {
+			t.Errorf("expected %s in active.en.toml to be %q, got %q", msg.ID, msg.Other, messages[msg.ID])
+		}
+	}
+}
+
+func TestGetPhaseFromString(t *testing.T) {
+	tests := map[string]Phase{
+		"lobby": LOBBY, "L": LOBBY, "game": TASKS, "t": TASKS, "disc": DISCUSS, "": UNINITIALIZED, "menu": UNINITIALIZED,
+		" lobby": UNINITIALIZED, "d ": UNINITIALIZED,
+	}
+	for input, expected := range tests {
+		if phase := GetPhaseFromString(input); phase != expected {
+			t.Errorf("GetPhaseFromString(%q): expected %d, got %d", input, expected, phase)
+		}
+	}
+}
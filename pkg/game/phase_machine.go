@@ -0,0 +1,177 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	ErrDuplicatePhase    = errors.New("already in that phase")
+	ErrInvalidTransition = errors.New("invalid phase transition")
+)
+
+// PhaseTransitions lists the phases each phase can change to. Capture can connect in the middle of a game, so an
+// uninitialized game can go anywhere, and players can quit to the menu from anywhere.
+var PhaseTransitions = map[Phase][]Phase{
+	UNINITIALIZED: {LOBBY, TASKS, DISCUSS, GAMEOVER, MENU},
+	MENU:          {LOBBY},
+	LOBBY:         {TASKS, MENU},
+	TASKS:         {DISCUSS, GAMEOVER, LOBBY, MENU},
+	DISCUSS:       {TASKS, GAMEOVER, LOBBY, MENU},
+	GAMEOVER:      {LOBBY, MENU},
+}
+
+func ValidTransition(from, to Phase) bool {
+	for _, p := range PhaseTransitions[from] {
+		if p == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Tolerance is how a PhaseMachine handles phase changes that can't happen, which flaky capture clients do send
+type Tolerance int
+
+const (
+	// Strict rejects duplicate phases and invalid transitions
+	Strict Tolerance = iota
+	// IgnoreDuplicates quietly drops duplicate phases, but still rejects invalid transitions
+	IgnoreDuplicates
+	// Lenient drops duplicates, and follows invalid transitions anyway, marking them Unexpected
+	Lenient
+)
+
+// PhaseTransition is emitted every time a PhaseMachine changes phase
+type PhaseTransition struct {
+	From PhaseNameString `json:"from"`
+	To   PhaseNameString `json:"to"`
+	// At is when the transition happened, and Duration is how long the game spent in From
+	At       time.Time     `json:"at"`
+	Duration time.Duration `json:"duration"`
+	// Unexpected transitions weren't valid, and were only followed because of a Lenient tolerance
+	Unexpected bool `json:"unexpected,omitempty"`
+
+	from, to Phase
+}
+
+func (t PhaseTransition) FromPhase() Phase {
+	return t.from
+}
+
+func (t PhaseTransition) ToPhase() Phase {
+	return t.to
+}
+
+// PhaseMachine tracks a game's phase, only following transitions that can actually happen
+type PhaseMachine struct {
+	lock    sync.Mutex
+	current Phase
+	// since is zero until the first transition, since time spent uninitialized isn't meaningful
+	since     time.Time
+	durations map[Phase]time.Duration
+	listeners []func(PhaseTransition)
+
+	Tolerance Tolerance
+	// Now is the clock, which tests replace
+	Now func() time.Time
+}
+
+func NewPhaseMachine(tolerance Tolerance) *PhaseMachine {
+	return &PhaseMachine{
+		current:   UNINITIALIZED,
+		durations: map[Phase]time.Duration{},
+		Tolerance: tolerance,
+		Now:       time.Now,
+	}
+}
+
+func (m *PhaseMachine) Current() Phase {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.current
+}
+
+// OnTransition registers fn to be called, in order, with every transition. It's called without the machine locked,
+// so it may use the machine.
+func (m *PhaseMachine) OnTransition(fn func(PhaseTransition)) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.listeners = append(m.listeners, fn)
+}
+
+// Transition moves the game to a new phase. It returns nil, with no error, when the tolerance says to ignore the
+// change.
+func (m *PhaseMachine) Transition(to Phase) (*PhaseTransition, error) {
+	if _, ok := PhaseNames[to]; !ok {
+		return nil, fmt.Errorf("%w: unknown phase %d", ErrInvalidTransition, to)
+	}
+
+	m.lock.Lock()
+	from := m.current
+	if from == to {
+		m.lock.Unlock()
+		if m.Tolerance == Strict {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicatePhase, PhaseNames[to])
+		}
+		return nil, nil
+	}
+	valid := ValidTransition(from, to)
+	if !valid && m.Tolerance != Lenient {
+		m.lock.Unlock()
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, PhaseNames[from], PhaseNames[to])
+	}
+
+	now := m.Now()
+	var spent time.Duration
+	if !m.since.IsZero() {
+		spent = now.Sub(m.since)
+	}
+	t := PhaseTransition{
+		From:       PhaseNames[from],
+		To:         PhaseNames[to],
+		At:         now,
+		Duration:   spent,
+		Unexpected: !valid,
+		from:       from,
+		to:         to,
+	}
+	if !m.since.IsZero() {
+		m.durations[from] += spent
+	}
+	m.current = to
+	m.since = now
+	listeners := m.listeners
+	m.lock.Unlock()
+
+	for _, fn := range listeners {
+		fn(t)
+	}
+	return &t, nil
+}
+
+// Reset returns the machine to UNINITIALIZED, like when capture disconnects, without emitting a transition. The time
+// spent in each phase is cleared too.
+func (m *PhaseMachine) Reset() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.current = UNINITIALIZED
+	m.since = time.Time{}
+	m.durations = map[Phase]time.Duration{}
+}
+
+// Durations is the total time spent in each phase, including the current one so far
+func (m *PhaseMachine) Durations() map[Phase]time.Duration {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	d := make(map[Phase]time.Duration, len(m.durations)+1)
+	for phase, v := range m.durations {
+		d[phase] = v
+	}
+	if !m.since.IsZero() {
+		d[m.current] += m.Now().Sub(m.since)
+	}
+	return d
+}
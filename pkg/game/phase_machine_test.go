@@ -0,0 +1,142 @@
+package game
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock advances by a second every time it's read
+func fakeClock() func() time.Time {
+	now := time.Unix(1600000000, 0)
+	return func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+}
+
+func TestPhaseMachineTolerance(t *testing.T) {
+	tests := []struct {
+		name      string
+		tolerance Tolerance
+		phases    []Phase
+		errs      []error
+		emitted   []Phase
+	}{
+		{
+			name:      "a full game",
+			tolerance: Strict,
+			phases:    []Phase{LOBBY, TASKS, DISCUSS, TASKS, GAMEOVER, LOBBY},
+			errs:      []error{nil, nil, nil, nil, nil, nil},
+			emitted:   []Phase{LOBBY, TASKS, DISCUSS, TASKS, GAMEOVER, LOBBY},
+		},
+		{
+			name:      "connected at game over",
+			tolerance: Strict,
+			phases:    []Phase{GAMEOVER, LOBBY},
+			errs:      []error{nil, nil},
+			emitted:   []Phase{GAMEOVER, LOBBY},
+		},
+		{
+			name:      "strict duplicate",
+			tolerance: Strict,
+			phases:    []Phase{LOBBY, TASKS, DISCUSS, DISCUSS},
+			errs:      []error{nil, nil, nil, ErrDuplicatePhase},
+			emitted:   []Phase{LOBBY, TASKS, DISCUSS},
+		},
+		{
+			name:      "ignored duplicate",
+			tolerance: IgnoreDuplicates,
+			phases:    []Phase{LOBBY, TASKS, DISCUSS, DISCUSS, TASKS},
+			errs:      []error{nil, nil, nil, nil, nil},
+			emitted:   []Phase{LOBBY, TASKS, DISCUSS, TASKS},
+		},
+		{
+			name:      "invalid transition",
+			tolerance: IgnoreDuplicates,
+			phases:    []Phase{LOBBY, DISCUSS, TASKS},
+			errs:      []error{nil, ErrInvalidTransition, nil},
+			emitted:   []Phase{LOBBY, TASKS},
+		},
+		{
+			name:      "lenient",
+			tolerance: Lenient,
+			phases:    []Phase{LOBBY, DISCUSS, DISCUSS},
+			errs:      []error{nil, nil, nil},
+			emitted:   []Phase{LOBBY, DISCUSS},
+		},
+		{
+			name:      "unknown phase",
+			tolerance: Lenient,
+			phases:    []Phase{Phase(42)},
+			errs:      []error{ErrInvalidTransition},
+		},
+	}
+	for _, test := range tests {
+		m := NewPhaseMachine(test.tolerance)
+		var emitted []Phase
+		m.OnTransition(func(t PhaseTransition) {
+			emitted = append(emitted, t.ToPhase())
+		})
+		for i, phase := range test.phases {
+			if _, err := m.Transition(phase); !errors.Is(err, test.errs[i]) {
+				t.Errorf("%s: transition %d to %d: expected %v, got %v", test.name, i, phase, test.errs[i], err)
+			}
+		}
+		if len(emitted) != len(test.emitted) {
+			t.Errorf("%s: expected transitions to %v, got %v", test.name, test.emitted, emitted)
+			continue
+		}
+		for i := range emitted {
+			if emitted[i] != test.emitted[i] {
+				t.Errorf("%s: expected transitions to %v, got %v", test.name, test.emitted, emitted)
+				break
+			}
+		}
+	}
+}
+
+func TestPhaseMachineEvents(t *testing.T) {
+	m := NewPhaseMachine(Lenient)
+	m.Now = fakeClock()
+
+	first, _ := m.Transition(LOBBY)
+	if first.From != "UNINITIALIZED" || first.To != "LOBBY" || first.Duration != 0 {
+		t.Errorf("unexpected first transition %+v", first)
+	}
+	tasks, _ := m.Transition(TASKS)
+	if tasks.Duration != time.Second || !tasks.At.Equal(first.At.Add(time.Second)) || tasks.Unexpected {
+		t.Errorf("unexpected transition %+v", tasks)
+	}
+	menu, _ := m.Transition(MENU)
+	if menu.FromPhase() != TASKS || menu.ToPhase() != MENU {
+		t.Errorf("unexpected transition %+v", menu)
+	}
+	if unexpected, _ := m.Transition(TASKS); !unexpected.Unexpected {
+		t.Error("expected MENU to TASKS to be marked unexpected")
+	}
+}
+
+func TestPhaseMachineDurations(t *testing.T) {
+	m := NewPhaseMachine(Strict)
+	m.Now = fakeClock()
+	for _, phase := range []Phase{LOBBY, TASKS, DISCUSS, TASKS} {
+		if _, err := m.Transition(phase); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// LOBBY 1s, TASKS 1s then 1s so far (Durations reads the clock), DISCUSS 1s
+	d := m.Durations()
+	if d[LOBBY] != time.Second || d[TASKS] != time.Second*2 || d[DISCUSS] != time.Second {
+		t.Errorf("unexpected durations %v", d)
+	}
+	if _, ok := d[UNINITIALIZED]; ok {
+		t.Error("expected no time to be counted before the first phase")
+	}
+
+	m.Reset()
+	if m.Current() != UNINITIALIZED || len(m.Durations()) != 0 {
+		t.Error("expected Reset to clear the machine")
+	}
+}
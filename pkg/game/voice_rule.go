@@ -1,21 +1,181 @@
 package game
 
+// PlayerState is a row of the voice rule matrix. The values are the keys of each phase's rules in MuteRules and
+// DeafRules, which originally only had "alive" and "dead".
+type PlayerState string
+
+const (
+	Alive     PlayerState = "alive"
+	Dead      PlayerState = "dead"
+	Spectator PlayerState = "spectator"
+	// Untracked players aren't linked to anyone in the game. Unless there's a rule for them, they're left alone.
+	Untracked PlayerState = "untracked"
+)
+
+var PlayerStates = []PlayerState{Alive, Dead, Spectator, Untracked}
+
+// VoiceState is what a voice rule decides for a player
+type VoiceState struct {
+	Mute bool
+	Deaf bool
+}
+
 type VoiceRules struct {
 	MuteRules map[PhaseNameString]map[string]bool
 	DeafRules map[PhaseNameString]map[string]bool
+
+	// overrides by voice channel ID and role ID, layered over the rules above
+	ChannelOverrides map[string]VoiceRuleOverride `json:"ChannelOverrides,omitempty"`
+	RoleOverrides    map[string]VoiceRuleOverride `json:"RoleOverrides,omitempty"`
+}
+
+// VoiceRuleOverride only replaces the phases and player states it has rules for
+type VoiceRuleOverride struct {
+	MuteRules map[PhaseNameString]map[string]bool `json:"MuteRules,omitempty"`
+	DeafRules map[PhaseNameString]map[string]bool `json:"DeafRules,omitempty"`
+}
+
+// VoiceContext is everything about a player that decides their voice state
+type VoiceContext struct {
+	Alive   bool
+	Tracked bool
+	// State takes precedence over Alive and Tracked when it's set
+	State     PlayerState
+	Phase     Phase
+	ChannelID string
+	RoleIDs   []string
+	// MuteSpectator decides how spectators are treated in phases the guild has no spectator rules for: like dead
+	// players if it's set, and like untracked players otherwise
+	MuteSpectator bool
+}
+
+func (ctx VoiceContext) PlayerState() PlayerState {
+	switch {
+	case ctx.State != "":
+		return ctx.State
+	case !ctx.Tracked:
+		return Untracked
+	case ctx.Alive:
+		return Alive
+	default:
+		return Dead
+	}
 }
 
 func (rules *VoiceRules) GetVoiceState(isAlive, isTracked bool, phase Phase) (bool, bool) {
-	if !isTracked {
-		return false, false
+	return rules.Resolve(VoiceContext{Alive: isAlive, Tracked: isTracked, Phase: phase})
+}
+
+// Resolve decides whether a player should be muted and deafened. The guild's rules are overridden by the rules for
+// the player's voice channel, and those by the rules for the player's roles. If several roles have a rule, the most
+// permissive wins, so a role can exempt players from being muted or deafened, whatever their other roles say.
+func (rules *VoiceRules) Resolve(ctx VoiceContext) (bool, bool) {
+	phase := PhaseNames[ctx.Phase]
+	state := string(ctx.PlayerState())
+	if state == string(Spectator) && !rules.Has(ctx.Phase, Spectator) {
+		state = string(Untracked)
+		if ctx.MuteSpectator {
+			state = string(Dead)
+		}
+	}
+
+	mute := rules.MuteRules[phase][state]
+	deaf := rules.DeafRules[phase][state]
+
+	if o, ok := rules.ChannelOverrides[ctx.ChannelID]; ok && ctx.ChannelID != "" {
+		if v, ok := lookupRule(o.MuteRules, phase, state); ok {
+			mute = v
+		}
+		if v, ok := lookupRule(o.DeafRules, phase, state); ok {
+			deaf = v
+		}
+	}
+
+	var roleMute, roleDeaf *bool
+	for _, roleID := range ctx.RoleIDs {
+		o, ok := rules.RoleOverrides[roleID]
+		if !ok {
+			continue
+		}
+		if v, ok := lookupRule(o.MuteRules, phase, state); ok && (roleMute == nil || !v) {
+			roleMute = &v
+		}
+		if v, ok := lookupRule(o.DeafRules, phase, state); ok && (roleDeaf == nil || !v) {
+			roleDeaf = &v
+		}
+	}
+	if roleMute != nil {
+		mute = *roleMute
+	}
+	if roleDeaf != nil {
+		deaf = *roleDeaf
+	}
+	return mute, deaf
+}
+
+func lookupRule(rules map[PhaseNameString]map[string]bool, phase PhaseNameString, state string) (bool, bool) {
+	v, ok := rules[phase][state]
+	return v, ok
+}
+
+// Get returns the guild's rule for a player state, ignoring overrides. States without a rule are neither muted nor
+// deafened.
+func (rules *VoiceRules) Get(phase Phase, state PlayerState) VoiceState {
+	return VoiceState{
+		Mute: rules.MuteRules[phase.ToString()][string(state)],
+		Deaf: rules.DeafRules[phase.ToString()][string(state)],
 	}
-	aliveStr := "dead"
-	if isAlive {
-		aliveStr = "alive"
+}
+
+// Set replaces the guild's rule for a player state
+func (rules *VoiceRules) Set(phase Phase, state PlayerState, vs VoiceState) {
+	rules.SetRule(true, phase, string(state), vs.Mute)
+	rules.SetRule(false, phase, string(state), vs.Deaf)
+}
+
+// Has reports whether the guild has a mute or deafen rule for the player state in the phase
+func (rules *VoiceRules) Has(phase Phase, state PlayerState) bool {
+	_, mute := rules.MuteRules[phase.ToString()][string(state)]
+	_, deaf := rules.DeafRules[phase.ToString()][string(state)]
+	return mute || deaf
+}
+
+// SetRule sets one of the guild's rules, creating the maps it needs
+func (rules *VoiceRules) SetRule(isMute bool, phase Phase, state string, val bool) {
+	if isMute {
+		rules.MuteRules = setRule(rules.MuteRules, phase, state, val)
+	} else {
+		rules.DeafRules = setRule(rules.DeafRules, phase, state, val)
+	}
+}
+
+// SetRule sets one of the override's rules, creating the maps it needs
+func (o *VoiceRuleOverride) SetRule(isMute bool, phase Phase, state string, val bool) {
+	if isMute {
+		o.MuteRules = setRule(o.MuteRules, phase, state, val)
+	} else {
+		o.DeafRules = setRule(o.DeafRules, phase, state, val)
 	}
-	phaseStr := PhaseNames[phase]
+}
 
-	return rules.MuteRules[phaseStr][aliveStr], rules.DeafRules[phaseStr][aliveStr]
+func (o *VoiceRuleOverride) Clone() VoiceRuleOverride {
+	return VoiceRuleOverride{
+		MuteRules: cloneRuleMap(o.MuteRules),
+		DeafRules: cloneRuleMap(o.DeafRules),
+	}
+}
+
+func setRule(rules map[PhaseNameString]map[string]bool, phase Phase, state string, val bool) map[PhaseNameString]map[string]bool {
+	if rules == nil {
+		rules = map[PhaseNameString]map[string]bool{}
+	}
+	states := rules[phase.ToString()]
+	if states == nil {
+		states = map[string]bool{}
+		rules[phase.ToString()] = states
+	}
+	states[state] = val
+	return rules
 }
 
 func MakeMuteAndDeafenRules() VoiceRules {
@@ -51,3 +211,38 @@ func MakeMuteAndDeafenRules() VoiceRules {
 	}
 	return rules
 }
+
+func (rules *VoiceRules) Clone() VoiceRules {
+	return VoiceRules{
+		MuteRules:        cloneRuleMap(rules.MuteRules),
+		DeafRules:        cloneRuleMap(rules.DeafRules),
+		ChannelOverrides: cloneOverrides(rules.ChannelOverrides),
+		RoleOverrides:    cloneOverrides(rules.RoleOverrides),
+	}
+}
+
+func cloneOverrides(m map[string]VoiceRuleOverride) map[string]VoiceRuleOverride {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]VoiceRuleOverride, len(m))
+	for id, o := range m {
+		c[id] = o.Clone()
+	}
+	return c
+}
+
+func cloneRuleMap(m map[PhaseNameString]map[string]bool) map[PhaseNameString]map[string]bool {
+	if m == nil {
+		return nil
+	}
+	c := make(map[PhaseNameString]map[string]bool, len(m))
+	for phase, states := range m {
+		inner := make(map[string]bool, len(states))
+		for k, v := range states {
+			inner[k] = v
+		}
+		c[phase] = inner
+	}
+	return c
+}
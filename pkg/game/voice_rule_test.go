@@ -0,0 +1,100 @@
+package game
+
+import "testing"
+
+func TestVoiceRulesResolve(t *testing.T) {
+	rules := MakeMuteAndDeafenRules()
+
+	lobby2 := VoiceRuleOverride{}
+	lobby2.SetRule(true, TASKS, "alive", false)
+	streamer := VoiceRuleOverride{}
+	streamer.SetRule(false, TASKS, "alive", false)
+	strict := VoiceRuleOverride{}
+	strict.SetRule(false, TASKS, "alive", true)
+	strict.SetRule(true, DISCUSS, "alive", true)
+
+	rules.ChannelOverrides = map[string]VoiceRuleOverride{"lobby2": lobby2}
+	rules.RoleOverrides = map[string]VoiceRuleOverride{"streamer": streamer, "strict": strict}
+
+	tests := []struct {
+		name       string
+		ctx        VoiceContext
+		mute, deaf bool
+	}{
+		{"guild rules", VoiceContext{Alive: true, Tracked: true, Phase: TASKS}, true, true},
+		{"untracked", VoiceContext{Alive: true, Phase: TASKS, RoleIDs: []string{"strict"}}, false, false},
+		{"channel override", VoiceContext{Alive: true, Tracked: true, Phase: TASKS, ChannelID: "lobby2"}, false, true},
+		{"other channel", VoiceContext{Alive: true, Tracked: true, Phase: TASKS, ChannelID: "lobby1"}, true, true},
+		{"role override", VoiceContext{Alive: true, Tracked: true, Phase: TASKS, RoleIDs: []string{"streamer"}}, true, false},
+		{"role over channel", VoiceContext{Alive: true, Tracked: true, Phase: DISCUSS, ChannelID: "lobby2", RoleIDs: []string{"strict"}}, true, false},
+		{"most permissive role", VoiceContext{Alive: true, Tracked: true, Phase: TASKS, RoleIDs: []string{"strict", "streamer"}}, true, false},
+		{"role without a rule for the phase", VoiceContext{Alive: false, Tracked: true, Phase: DISCUSS, RoleIDs: []string{"streamer"}}, true, false},
+	}
+	for _, test := range tests {
+		mute, deaf := rules.Resolve(test.ctx)
+		if mute != test.mute || deaf != test.deaf {
+			t.Errorf("%s: expected mute %v deaf %v, got mute %v deaf %v", test.name, test.mute, test.deaf, mute, deaf)
+		}
+	}
+}
+
+func TestVoiceRulesCloneOverrides(t *testing.T) {
+	rules := MakeMuteAndDeafenRules()
+	o := VoiceRuleOverride{}
+	o.SetRule(true, TASKS, "alive", false)
+	rules.RoleOverrides = map[string]VoiceRuleOverride{"role": o}
+
+	c := rules.Clone()
+	c.RoleOverrides["role"].MuteRules[PhaseNames[TASKS]]["alive"] = true
+
+	if rules.RoleOverrides["role"].MuteRules[PhaseNames[TASKS]]["alive"] {
+		t.Error("changing a clone's overrides changed the original")
+	}
+}
+
+func TestVoiceRulesStateMatrix(t *testing.T) {
+	rules := MakeMuteAndDeafenRules()
+	rules.Set(DISCUSS, Spectator, VoiceState{Mute: true})
+	rules.Set(LOBBY, Untracked, VoiceState{Mute: true, Deaf: true})
+
+	tests := []struct {
+		name          string
+		phase         Phase
+		state         PlayerState
+		muteSpectator bool
+		expected      VoiceState
+	}{
+		{"alive in tasks", TASKS, Alive, false, VoiceState{Mute: true, Deaf: true}},
+		{"dead in tasks", TASKS, Dead, false, VoiceState{}},
+		{"dead in discussion", DISCUSS, Dead, false, VoiceState{Mute: true}},
+		{"spectator rule", DISCUSS, Spectator, false, VoiceState{Mute: true}},
+		{"spectator rule ignores the flag", DISCUSS, Spectator, true, VoiceState{Mute: true}},
+		{"spectator without a rule", TASKS, Spectator, false, VoiceState{}},
+		{"spectator without a rule, muted like the dead", DISCUSS, Spectator, true, VoiceState{Mute: true}},
+		{"untracked without a rule", TASKS, Untracked, false, VoiceState{}},
+		{"untracked rule", LOBBY, Untracked, false, VoiceState{Mute: true, Deaf: true}},
+	}
+	for _, test := range tests {
+		mute, deaf := rules.Resolve(VoiceContext{Phase: test.phase, State: test.state, MuteSpectator: test.muteSpectator})
+		if (VoiceState{Mute: mute, Deaf: deaf}) != test.expected {
+			t.Errorf("%s: expected %+v, got mute %v deaf %v", test.name, test.expected, mute, deaf)
+		}
+	}
+}
+
+func TestVoiceRulesSetRule(t *testing.T) {
+	tests := []struct {
+		isMute   bool
+		expected VoiceState
+	}{
+		{true, VoiceState{Mute: true}},
+		{false, VoiceState{Deaf: true}},
+	}
+	for _, test := range tests {
+		rules := VoiceRules{}
+		rules.SetRule(test.isMute, LOBBY, string(Dead), true)
+		if got := rules.Get(LOBBY, Dead); got != test.expected {
+			t.Errorf("SetRule(isMute=%v): expected %+v, got %+v", test.isMute, test.expected, got)
+		}
+	}
+}
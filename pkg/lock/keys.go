@@ -0,0 +1,24 @@
+package lock
+
+import (
+	"time"
+
+	"github.com/das08/utils/pkg/rediskey"
+	"github.com/go-redis/redis/v8"
+)
+
+func ForSnowflake(client *redis.Client, snowflake string, ttl time.Duration) *Lock {
+	return New(client, rediskey.SnowflakeLockID(snowflake), ttl)
+}
+
+func ForVoiceChanges(client *redis.Client, connectCode string, ttl time.Duration) *Lock {
+	return New(client, rediskey.VoiceChangesForGameCodeLock(connectCode), ttl)
+}
+
+func ForGuildToken(client *redis.Client, guildID, hToken string, ttl time.Duration) *Lock {
+	return New(client, rediskey.GuildTokenLock(guildID, hToken), ttl)
+}
+
+func ForTokenIdentify(client *redis.Client, token string, ttl time.Duration) *Lock {
+	return New(client, rediskey.BotTokenIdentifyLock(token), ttl)
+}
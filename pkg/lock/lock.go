@@ -0,0 +1,148 @@
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	mrand "math/rand"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	DefaultMinBackoff = time.Millisecond * 10
+	DefaultMaxBackoff = time.Millisecond * 500
+)
+
+var ErrNotHeld = errors.New("lock is not held by this owner")
+
+// ErrInvalidTTL is returned instead of taking a lock that would never expire
+var ErrInvalidTTL = errors.New("lock TTL must be positive")
+
+// only touch the key if it still holds our token, so an owner whose lock expired can't release or extend somebody
+// else's
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+var extendScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// Lock is a Redis lock owned by a random token, so only the holder can release or extend it
+type Lock struct {
+	client *redis.Client
+	key    string
+	token  string
+	ttl    time.Duration
+
+	// Acquire retries with exponential backoff (and jitter) between these bounds. Values <= 0 use the defaults.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+func New(client *redis.Client, key string, ttl time.Duration) *Lock {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return &Lock{
+		client:     client,
+		key:        key,
+		token:      hex.EncodeToString(b),
+		ttl:        ttl,
+		MinBackoff: DefaultMinBackoff,
+		MaxBackoff: DefaultMaxBackoff,
+	}
+}
+
+func (l *Lock) Key() string {
+	return l.key
+}
+
+func (l *Lock) Token() string {
+	return l.token
+}
+
+// TryAcquire takes the lock if it's free, without waiting
+func (l *Lock) TryAcquire(ctx context.Context) (bool, error) {
+	if l.ttl <= 0 {
+		return false, ErrInvalidTTL
+	}
+	return l.client.SetNX(ctx, l.key, l.token, l.ttl).Result()
+}
+
+// Acquire waits until the lock is taken, or returns the context's error if it's done first
+func (l *Lock) Acquire(ctx context.Context) error {
+	backoff := l.MinBackoff
+	if backoff <= 0 {
+		backoff = DefaultMinBackoff
+	}
+	maxBackoff := l.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+	if maxBackoff < backoff {
+		maxBackoff = backoff
+	}
+	for {
+		acquired, err := l.TryAcquire(ctx)
+		if err != nil {
+			return err
+		}
+		if acquired {
+			return nil
+		}
+
+		wait := backoff/2 + time.Duration(mrand.Int63n(int64(backoff/2)+1))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// Release frees the lock, returning ErrNotHeld if it expired or was taken by someone else in the meantime
+func (l *Lock) Release(ctx context.Context) error {
+	n, err := releaseScript.Run(ctx, l.client, []string{l.key}, l.token).Int64()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+// Extend resets the lock's time to live, returning ErrNotHeld if it's no longer ours
+func (l *Lock) Extend(ctx context.Context, ttl time.Duration) error {
+	if ttl <= 0 {
+		return ErrInvalidTTL
+	}
+	n, err := extendScript.Run(ctx, l.client, []string{l.key}, l.token, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotHeld
+	}
+	l.ttl = ttl
+	return nil
+}
+
+// TTL is how long the lock has left, whoever holds it. Negative values follow PTTL: -2 means nobody holds it.
+func (l *Lock) TTL(ctx context.Context) (time.Duration, error) {
+	return l.client.PTTL(ctx, l.key).Result()
+}
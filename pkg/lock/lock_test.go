@@ -0,0 +1,111 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return mr, client
+}
+
+func TestOwnership(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+
+	first := ForSnowflake(client, "141101495071408128", time.Minute)
+	second := ForSnowflake(client, "141101495071408128", time.Minute)
+
+	if acquired, err := first.TryAcquire(ctx); err != nil || !acquired {
+		t.Fatalf("expected to acquire a free lock, got %v, %v", acquired, err)
+	}
+	if acquired, err := second.TryAcquire(ctx); err != nil || acquired {
+		t.Fatalf("expected not to acquire a held lock, got %v, %v", acquired, err)
+	}
+	if err := second.Release(ctx); err != ErrNotHeld {
+		t.Errorf("expected ErrNotHeld releasing someone else's lock, got %v", err)
+	}
+	if err := second.Extend(ctx, time.Hour); err != ErrNotHeld {
+		t.Errorf("expected ErrNotHeld extending someone else's lock, got %v", err)
+	}
+
+	if err := first.Extend(ctx, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := mr.TTL(first.Key()); ttl != time.Hour {
+		t.Errorf("expected the TTL to be extended to an hour, got %s", ttl)
+	}
+	if err := first.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Release(ctx); err != ErrNotHeld {
+		t.Errorf("expected ErrNotHeld releasing twice, got %v", err)
+	}
+}
+
+func TestAcquireWaits(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+
+	holder := ForVoiceChanges(client, "ABCDEF", time.Minute)
+	if err := holder.Acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, time.Millisecond*50)
+	defer cancel()
+	waiter := ForVoiceChanges(client, "ABCDEF", time.Minute)
+	if err := waiter.Acquire(timeout); err != context.DeadlineExceeded {
+		t.Errorf("expected the context deadline while the lock is held, got %v", err)
+	}
+
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		_ = holder.Release(ctx)
+	}()
+	if err := waiter.Acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := waiter.Release(ctx); err != nil {
+		t.Errorf("expected the waiter to own the lock once acquired, got %v", err)
+	}
+}
+
+func TestInvalidSettings(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+
+	forever := New(client, "key", 0)
+	if _, err := forever.TryAcquire(ctx); !errors.Is(err, ErrInvalidTTL) {
+		t.Errorf("expected ErrInvalidTTL taking a lock that never expires, got %v", err)
+	}
+
+	holder := New(client, "key", time.Minute)
+	if err := holder.Acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := holder.Extend(ctx, 0); !errors.Is(err, ErrInvalidTTL) {
+		t.Errorf("expected ErrInvalidTTL extending without a TTL, got %v", err)
+	}
+
+	// a MaxBackoff of 0 falls back to the default rather than retrying in a busy loop
+	waiter := New(client, "key", time.Minute)
+	waiter.MaxBackoff = 0
+	timeout, cancel := context.WithTimeout(ctx, time.Millisecond*50)
+	defer cancel()
+	before := mr.CommandCount()
+	if err := waiter.Acquire(timeout); err != context.DeadlineExceeded {
+		t.Errorf("expected the context deadline while the lock is held, got %v", err)
+	}
+	if attempts := mr.CommandCount() - before; attempts > 10 {
+		t.Errorf("expected Acquire to back off, got %d attempts in 50ms", attempts)
+	}
+}
@@ -0,0 +1,21 @@
+package logging
+
+import "log"
+
+// Logger is what the packages in this module log through. It is satisfied by *log.Logger, and a nil Logger disables
+// logging.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Default is the Logger everything uses unless it's given another one: the standard library's default logger
+func Default() Logger {
+	return log.Default()
+}
+
+// Printf logs to l, unless it's nil
+func Printf(l Logger, format string, v ...interface{}) {
+	if l != nil {
+		l.Printf(format, v...)
+	}
+}
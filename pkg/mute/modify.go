@@ -0,0 +1,106 @@
+package mute
+
+import (
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/das08/utils/pkg/premium"
+	"github.com/das08/utils/pkg/taskid"
+)
+
+type UserModify struct {
+	UserID uint64 `json:"userID"`
+	Mute   bool   `json:"mute"`
+	Deaf   bool   `json:"deaf"`
+
+	// optional; see PatchParams
+	ChannelID *string `json:"channelID,omitempty"`
+	Nick      *string `json:"nick,omitempty"`
+}
+
+// Params is the PATCH body that applies this modification
+func (u UserModify) Params() PatchParams {
+	return PatchParams{
+		Deaf:      u.Deaf,
+		Mute:      u.Mute,
+		ChannelID: u.ChannelID,
+		Nick:      u.Nick,
+	}
+}
+
+type UserModifyRequest struct {
+	Premium premium.Tier `json:"premium"`
+	Users   []UserModify `json:"users"`
+}
+
+type ModifyTask struct {
+	GuildID    uint64      `json:"guildID"`
+	UserID     uint64      `json:"userID"`
+	Parameters PatchParams `json:"parameters"`
+	TaskID     string      `json:"taskID"`
+}
+
+const IDLength = taskid.Length
+
+func NewModifyTask(guildID, userID uint64, params PatchParams) ModifyTask {
+	return ModifyTask{
+		GuildID:    guildID,
+		UserID:     userID,
+		Parameters: params,
+		TaskID:     taskid.New(),
+	}
+}
+
+// CreatedAt recovers when the task was made from its ID
+func (t ModifyTask) CreatedAt() (time.Time, error) {
+	return taskid.Time(t.TaskID)
+}
+
+// PatchParams is the body of a guild member PATCH. Mute and Deaf are always sent; ChannelID and Nick are only sent
+// when set, so a nil ChannelID leaves the member where they are (rather than disconnecting them), and a nil Nick
+// leaves their nickname alone. An empty Nick resets it.
+type PatchParams struct {
+	Deaf      bool    `json:"deaf"`
+	Mute      bool    `json:"mute"`
+	ChannelID *string `json:"channel_id,omitempty"`
+	Nick      *string `json:"nick,omitempty"`
+}
+
+// WithChannel also moves the member to the given voice channel
+func (p PatchParams) WithChannel(channelID string) PatchParams {
+	p.ChannelID = &channelID
+	return p
+}
+
+// WithNick also changes the member's nickname
+func (p PatchParams) WithNick(nick string) PatchParams {
+	p.Nick = &nick
+	return p
+}
+
+func ApplyMuteDeaf(sess *discordgo.Session, guildID, userID string, mute, deaf bool) error {
+	return ApplyPatch(sess, guildID, userID, PatchParams{
+		Deaf: deaf,
+		Mute: mute,
+	})
+}
+
+func ApplyPatch(sess *discordgo.Session, guildID, userID string, p PatchParams) error {
+	_, err := sess.RequestWithBucketID("PATCH", discordgo.EndpointGuildMember(guildID, userID), p, discordgo.EndpointGuildMember(guildID, ""))
+	return err
+}
+
+// a response indicating how the mutes/deafens were issued, and if ratelimits occurred
+type MuteDeafenSuccessCounts struct {
+	Worker    int64 `json:"worker"`
+	Capture   int64 `json:"capture"`
+	Official  int64 `json:"official"`
+	RateLimit int64 `json:"ratelimit"`
+}
+
+func (c *MuteDeafenSuccessCounts) Add(other MuteDeafenSuccessCounts) {
+	c.Worker += other.Worker
+	c.Capture += other.Capture
+	c.Official += other.Official
+	c.RateLimit += other.RateLimit
+}
@@ -0,0 +1,26 @@
+package mute
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPatchParamsJSON(t *testing.T) {
+	b, _ := json.Marshal(PatchParams{Mute: true})
+	if string(b) != `{"deaf":false,"mute":true}` {
+		t.Errorf("unset channel and nick should be omitted, got %s", b)
+	}
+
+	b, _ = json.Marshal(PatchParams{Deaf: true}.WithChannel("141101495071408128").WithNick(""))
+	if string(b) != `{"deaf":true,"mute":false,"channel_id":"141101495071408128","nick":""}` {
+		t.Errorf("unexpected encoding with channel and nick: %s", b)
+	}
+}
+
+func TestUserModifyParams(t *testing.T) {
+	channel := "141101495071408128"
+	p := UserModify{UserID: 1, Mute: true, ChannelID: &channel}.Params()
+	if !p.Mute || p.Deaf || p.ChannelID == nil || *p.ChannelID != channel || p.Nick != nil {
+		t.Errorf("unexpected params: %+v", p)
+	}
+}
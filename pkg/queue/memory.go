@@ -0,0 +1,323 @@
+package queue
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SubscriptionBuffer is how many unread messages a Memory subscription holds before new ones are dropped, like a slow
+// Redis subscriber being disconnected rather than blocking publishers
+const SubscriptionBuffer = 100
+
+// Memory is a Broker that lives entirely in-process, for tests and single-process self-hosting
+type Memory struct {
+	lock    sync.Mutex
+	lists   map[string]*memoryList
+	expiry  map[string]time.Time
+	leases  map[string]map[string]memoryLease
+	waiters map[string][]chan struct{}
+	subs    map[string]map[*memorySubscription]struct{}
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		lists:   make(map[string]*memoryList),
+		expiry:  make(map[string]time.Time),
+		leases:  make(map[string]map[string]memoryLease),
+		waiters: make(map[string][]chan struct{}),
+		subs:    make(map[string]map[*memorySubscription]struct{}),
+	}
+}
+
+// memoryList pops from the front by advancing head, and compacts once most of the backing array is behind it, so
+// popped elements don't stay reachable for the life of the list
+type memoryList struct {
+	items []string
+	head  int
+}
+
+func (l *memoryList) len() int {
+	return len(l.items) - l.head
+}
+
+func (l *memoryList) popFront() string {
+	v := l.items[l.head]
+	l.items[l.head] = ""
+	l.head++
+	if l.head*2 >= len(l.items) {
+		l.items = append([]string(nil), l.items[l.head:]...)
+		l.head = 0
+	}
+	return v
+}
+
+func (l *memoryList) pushFront(values ...string) {
+	l.items = append(append([]string(nil), values...), l.items[l.head:]...)
+	l.head = 0
+}
+
+type memoryLease struct {
+	value    string
+	consumer string
+	token    string
+	deadline time.Time
+}
+
+// expire drops key if its TTL has passed. Must be called with the lock held.
+func (m *Memory) expire(key string) {
+	if exp, ok := m.expiry[key]; ok && !time.Now().Before(exp) {
+		delete(m.lists, key)
+		delete(m.expiry, key)
+	}
+}
+
+// pop must be called with the lock held
+func (m *Memory) pop(key string) (string, bool) {
+	m.expire(key)
+	list, ok := m.lists[key]
+	if !ok {
+		return "", false
+	}
+	v := list.popFront()
+	if list.len() == 0 {
+		// like Redis, an empty list no longer exists (and loses its TTL)
+		delete(m.lists, key)
+		delete(m.expiry, key)
+	}
+	return v, true
+}
+
+// wake must be called with the lock held
+func (m *Memory) wake(key string) {
+	for _, w := range m.waiters[key] {
+		close(w)
+	}
+	delete(m.waiters, key)
+}
+
+func (m *Memory) RPush(ctx context.Context, key string, values ...string) (int64, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.rpush(key, values), nil
+}
+
+// rpush must be called with the lock held
+func (m *Memory) rpush(key string, values []string) int64 {
+	m.expire(key)
+	list, ok := m.lists[key]
+	if !ok {
+		list = &memoryList{}
+		m.lists[key] = list
+	}
+	list.items = append(list.items, values...)
+	m.wake(key)
+	return int64(list.len())
+}
+
+func (m *Memory) RPushTTL(ctx context.Context, key string, ttl time.Duration, values ...string) (int64, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	count := m.rpush(key, values)
+	if count == int64(len(values)) {
+		m.expiry[key] = time.Now().Add(ttl)
+	}
+	return count, nil
+}
+
+func (m *Memory) LPop(ctx context.Context, key string) (string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if v, ok := m.pop(key); ok {
+		return v, nil
+	}
+	return "", Nil
+}
+
+func (m *Memory) BLPop(ctx context.Context, timeout time.Duration, key string) (string, error) {
+	var timer <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		timer = t.C
+	}
+
+	for {
+		m.lock.Lock()
+		if v, ok := m.pop(key); ok {
+			m.lock.Unlock()
+			return v, nil
+		}
+		wake := make(chan struct{})
+		m.waiters[key] = append(m.waiters[key], wake)
+		m.lock.Unlock()
+
+		select {
+		case <-wake:
+			// another waiter may have taken the element first, so go around and check again
+		case <-timer:
+			m.removeWaiter(key, wake)
+			return "", Nil
+		case <-ctx.Done():
+			m.removeWaiter(key, wake)
+			return "", ctx.Err()
+		}
+	}
+}
+
+func (m *Memory) removeWaiter(key string, wake chan struct{}) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	waiters := m.waiters[key]
+	for i, w := range waiters {
+		if w == wake {
+			m.waiters[key] = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(m.waiters[key]) == 0 {
+		delete(m.waiters, key)
+	}
+}
+
+func (m *Memory) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.expire(key)
+	if _, ok := m.lists[key]; ok {
+		m.expiry[key] = time.Now().Add(ttl)
+	}
+	return nil
+}
+
+func (m *Memory) Claim(ctx context.Context, key, consumer string, visibility time.Duration) (Lease, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	v, ok := m.pop(key)
+	if !ok {
+		return Lease{}, Nil
+	}
+	lease := Lease{Value: v, Consumer: consumer, Token: newLeaseToken()}
+	if m.leases[key] == nil {
+		m.leases[key] = make(map[string]memoryLease)
+	}
+	m.leases[key][lease.Token] = memoryLease{value: v, consumer: consumer, token: lease.Token, deadline: time.Now().Add(visibility)}
+	return lease, nil
+}
+
+func (m *Memory) Ack(ctx context.Context, key string, lease Lease) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.leases[key][lease.Token]; !ok {
+		return ErrNotLeased
+	}
+	delete(m.leases[key], lease.Token)
+	if len(m.leases[key]) == 0 {
+		delete(m.leases, key)
+	}
+	return nil
+}
+
+func (m *Memory) Requeue(ctx context.Context, key string) (int64, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	now := time.Now()
+	var expired []memoryLease
+	for token, lease := range m.leases[key] {
+		if !lease.deadline.After(now) {
+			expired = append(expired, lease)
+			delete(m.leases[key], token)
+		}
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+	if len(m.leases[key]) == 0 {
+		delete(m.leases, key)
+	}
+	// tokens sort by when they were made, which keeps leases with the same deadline in the order they were claimed
+	sort.Slice(expired, func(i, j int) bool {
+		if !expired[i].deadline.Equal(expired[j].deadline) {
+			return expired[i].deadline.Before(expired[j].deadline)
+		}
+		return expired[i].token < expired[j].token
+	})
+
+	values := make([]string, len(expired))
+	for i, lease := range expired {
+		values[i] = lease.value
+	}
+	m.expire(key)
+	list, ok := m.lists[key]
+	if !ok {
+		list = &memoryList{}
+		m.lists[key] = list
+	}
+	list.pushFront(values...)
+	m.wake(key)
+	return int64(len(values)), nil
+}
+
+func (m *Memory) Publish(ctx context.Context, channel, message string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for sub := range m.subs[channel] {
+		select {
+		case sub.ch <- message:
+		default:
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Subscribe(ctx context.Context, channel string) (Subscription, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	sub := &memorySubscription{
+		memory:  m,
+		channel: channel,
+		ch:      make(chan string, SubscriptionBuffer),
+	}
+	if m.subs[channel] == nil {
+		m.subs[channel] = make(map[*memorySubscription]struct{})
+	}
+	m.subs[channel][sub] = struct{}{}
+	return sub, nil
+}
+
+type memorySubscription struct {
+	memory  *Memory
+	channel string
+	ch      chan string
+}
+
+func (s *memorySubscription) Channel() <-chan string {
+	return s.ch
+}
+
+func (s *memorySubscription) Close() error {
+	s.memory.lock.Lock()
+	defer s.memory.lock.Unlock()
+
+	if _, ok := s.memory.subs[s.channel][s]; !ok {
+		return nil
+	}
+	delete(s.memory.subs[s.channel], s)
+	if len(s.memory.subs[s.channel]) == 0 {
+		delete(s.memory.subs, s.channel)
+	}
+	close(s.ch)
+	return nil
+}
+
+var _ Broker = (*Memory)(nil)
@@ -0,0 +1,78 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/das08/utils/pkg/taskid"
+	"github.com/go-redis/redis/v8"
+)
+
+// Nil is returned when there is nothing to pop. It is redis.Nil, so callers that already check for redis.Nil work
+// unchanged against any implementation.
+var Nil = redis.Nil
+
+var ErrNotLeased = errors.New("not leased; it was already acked or requeued")
+
+// LeaseTTL is how long the bookkeeping for a list's leases is kept after the last Claim
+const LeaseTTL = time.Hour
+
+// Queue is the subset of Redis list operations used to pass jobs and events between processes
+type Queue interface {
+	// RPush appends values to the list at key, returning the new length of the list
+	RPush(ctx context.Context, key string, values ...string) (int64, error)
+	// RPushTTL is RPush, but also sets ttl on the list if the push created it. Both happen together, so a failure
+	// can't leave the values pushed but the list without a time to live.
+	RPushTTL(ctx context.Context, key string, ttl time.Duration, values ...string) (int64, error)
+	// LPop removes and returns the head of the list at key, or Nil if it is empty
+	LPop(ctx context.Context, key string) (string, error)
+	// BLPop is LPop, but waits up to timeout for an element to arrive. A timeout of 0 waits forever.
+	BLPop(ctx context.Context, timeout time.Duration, key string) (string, error)
+	// Expire sets a time to live on key. It has no effect if the key doesn't exist.
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+}
+
+// Lease is an element claimed from a Reliable queue. Value is the element itself; Token identifies this particular
+// claim, so identical elements can be leased and acked independently.
+type Lease struct {
+	Value    string
+	Consumer string
+	Token    string
+}
+
+// Reliable is at-least-once delivery on top of a Queue. A claimed element stays leased to its consumer until it is
+// acked, and if that doesn't happen in time, Requeue puts it back on the front of the list for another consumer.
+type Reliable interface {
+	// Claim leases the head of the list at key to consumer for visibility, or returns Nil if the list is empty
+	Claim(ctx context.Context, key, consumer string, visibility time.Duration) (Lease, error)
+	// Ack releases a lease for good. It returns ErrNotLeased if the lease was already acked or requeued.
+	Ack(ctx context.Context, key string, lease Lease) error
+	// Requeue returns every element whose lease has expired to the front of the list, oldest deadline first, and
+	// returns how many there were
+	Requeue(ctx context.Context, key string) (int64, error)
+}
+
+type Subscription interface {
+	// Channel delivers the messages published to the subscribed channel, and is closed by Close
+	Channel() <-chan string
+	Close() error
+}
+
+type PubSub interface {
+	Publish(ctx context.Context, channel, message string) error
+	// Subscribe returns once the subscription is active, so anything published afterwards will be delivered
+	Subscribe(ctx context.Context, channel string) (Subscription, error)
+}
+
+type Broker interface {
+	Queue
+	Reliable
+	PubSub
+}
+
+// newLeaseToken is a task ID, so tokens sort in the order they were made. Redis orders leases with the same deadline
+// by token, so they're requeued in the order they were claimed.
+func newLeaseToken() string {
+	return taskid.New()
+}
@@ -0,0 +1,255 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// brokers runs each test against both implementations, so Memory is held to the same semantics as Redis
+func brokers(t *testing.T) map[string]Broker {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return map[string]Broker{
+		"redis":  NewRedis(client),
+		"memory": NewMemory(),
+	}
+}
+
+func TestPushPop(t *testing.T) {
+	ctx := context.Background()
+	for name, b := range brokers(t) {
+		count, err := b.RPush(ctx, "list", "a", "b")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 2 {
+			t.Errorf("%s: expected length 2, got %d", name, count)
+		}
+		for _, expected := range []string{"a", "b"} {
+			v, err := b.LPop(ctx, "list")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v != expected {
+				t.Errorf("%s: expected %s, got %s", name, expected, v)
+			}
+		}
+		if _, err := b.LPop(ctx, "list"); !errors.Is(err, Nil) {
+			t.Errorf("%s: expected Nil popping an empty list, got %v", name, err)
+		}
+	}
+}
+
+func TestBLPop(t *testing.T) {
+	ctx := context.Background()
+	for name, b := range brokers(t) {
+		start := time.Now()
+		_, err := b.BLPop(ctx, time.Millisecond*50, "list")
+		if !errors.Is(err, Nil) {
+			t.Errorf("%s: expected Nil after the timeout, got %v", name, err)
+		}
+		if time.Since(start) < time.Millisecond*50 {
+			t.Errorf("%s: BLPop returned before its timeout", name)
+		}
+
+		go func() {
+			time.Sleep(time.Millisecond * 20)
+			_, _ = b.RPush(ctx, "list", "late")
+		}()
+		v, err := b.BLPop(ctx, time.Second, "list")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != "late" {
+			t.Errorf("%s: expected late, got %s", name, v)
+		}
+	}
+}
+
+func TestExpire(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemory()
+
+	if err := b.Expire(ctx, "missing", time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.RPush(ctx, "list", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Expire(ctx, "list", time.Millisecond*10); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 20)
+	if _, err := b.LPop(ctx, "list"); !errors.Is(err, Nil) {
+		t.Errorf("expected the list to have expired, got %v", err)
+	}
+}
+
+func TestRPushTTL(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	r := NewRedis(client)
+	if _, err := r.RPushTTL(ctx, "list", time.Minute, "a"); err != nil {
+		t.Fatal(err)
+	}
+	mr.FastForward(time.Second * 30)
+	// only the push that creates the list sets its TTL
+	if count, err := r.RPushTTL(ctx, "list", time.Hour, "b", "c"); err != nil || count != 3 {
+		t.Fatalf("expected a list of 3, got %d, %v", count, err)
+	}
+	if ttl := mr.TTL("list"); ttl != time.Second*30 {
+		t.Errorf("expected the first push's TTL to be kept, got %s", ttl)
+	}
+
+	m := NewMemory()
+	if _, err := m.RPushTTL(ctx, "list", time.Millisecond*30, "a"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 20)
+	if _, err := m.RPushTTL(ctx, "list", time.Hour, "b"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 20)
+	if _, err := m.LPop(ctx, "list"); !errors.Is(err, Nil) {
+		t.Errorf("expected the list to have expired, got %v", err)
+	}
+}
+
+func TestReliable(t *testing.T) {
+	ctx := context.Background()
+	for name, b := range brokers(t) {
+		// identical values are leased separately
+		if _, err := b.RPush(ctx, "list", "same", "same", "other"); err != nil {
+			t.Fatal(err)
+		}
+		first, err := b.Claim(ctx, "list", "crashed", time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := b.Claim(ctx, "list", "alive", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first.Value != "same" || second.Value != "same" || first.Token == second.Token {
+			t.Fatalf("%s: unexpected leases %+v, %+v", name, first, second)
+		}
+
+		time.Sleep(time.Millisecond * 5)
+		count, err := b.Requeue(ctx, "list")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Errorf("%s: expected 1 expired lease to be requeued, got %d", name, count)
+		}
+		if err := b.Ack(ctx, "list", first); !errors.Is(err, ErrNotLeased) {
+			t.Errorf("%s: expected ErrNotLeased acking a requeued lease, got %v", name, err)
+		}
+		if err := b.Ack(ctx, "list", second); err != nil {
+			t.Errorf("%s: expected the unexpired lease to be acked, got %v", name, err)
+		}
+		if err := b.Ack(ctx, "list", second); !errors.Is(err, ErrNotLeased) {
+			t.Errorf("%s: expected ErrNotLeased acking twice, got %v", name, err)
+		}
+
+		// the requeued value goes back in front of the rest of the list
+		for _, expected := range []string{"same", "other"} {
+			lease, err := b.Claim(ctx, "list", "replacement", time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lease.Value != expected {
+				t.Errorf("%s: expected %s, got %s", name, expected, lease.Value)
+			}
+		}
+		if _, err := b.Claim(ctx, "list", "replacement", time.Minute); !errors.Is(err, Nil) {
+			t.Errorf("%s: expected Nil claiming from an empty list, got %v", name, err)
+		}
+	}
+}
+
+func TestRequeueWithoutOwner(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	r := NewRedis(client)
+	if _, err := r.RPush(ctx, "list", "a"); err != nil {
+		t.Fatal(err)
+	}
+	lease, err := r.Claim(ctx, "list", "worker", time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr.HDel(LeaseOwnersKey("list"), lease.Token)
+	time.Sleep(time.Millisecond * 5)
+
+	if count, err := r.Requeue(ctx, "list"); err != nil || count != 0 {
+		t.Errorf("expected a lease without an owner to be skipped, got %d, %v", count, err)
+	}
+	if mr.Exists("list") {
+		t.Error("expected the value not to be pushed back while it's still on the processing list")
+	}
+}
+
+func TestMemoryPopReleases(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemory()
+	for i := 0; i < 100; i++ {
+		if _, err := b.RPush(ctx, "list", "v"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 99; i++ {
+		if _, err := b.LPop(ctx, "list"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if list := b.lists["list"]; list.len() != 1 || len(list.items) > 2 {
+		t.Errorf("expected popped elements to be released, have %d items backing a list of %d", len(list.items), list.len())
+	}
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	ctx := context.Background()
+	for name, b := range brokers(t) {
+		sub, err := b.Subscribe(ctx, "channel")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Publish(ctx, "channel", "hello"); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case msg := <-sub.Channel():
+			if msg != "hello" {
+				t.Errorf("%s: expected hello, got %s", name, msg)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("%s: subscriber never received the message", name)
+		}
+
+		if err := sub.Close(); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case _, ok := <-sub.Channel():
+			if ok {
+				t.Errorf("%s: received a message after Close", name)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("%s: channel was not closed by Close", name)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Redis is a Broker backed by a Redis server
+type Redis struct {
+	client *redis.Client
+}
+
+func NewRedis(client *redis.Client) *Redis {
+	return &Redis{client: client}
+}
+
+func (r *Redis) RPush(ctx context.Context, key string, values ...string) (int64, error) {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return r.client.RPush(ctx, key, args...).Result()
+}
+
+var rpushTTLScript = redis.NewScript(`
+local count = redis.call('RPUSH', KEYS[1], unpack(ARGV, 2))
+if count == #ARGV - 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return count
+`)
+
+func (r *Redis) RPushTTL(ctx context.Context, key string, ttl time.Duration, values ...string) (int64, error) {
+	args := make([]interface{}, 0, len(values)+1)
+	args = append(args, ttl.Milliseconds())
+	for _, v := range values {
+		args = append(args, v)
+	}
+	return rpushTTLScript.Run(ctx, r.client, []string{key}, args...).Int64()
+}
+
+func (r *Redis) LPop(ctx context.Context, key string) (string, error) {
+	return r.client.LPop(ctx, key).Result()
+}
+
+func (r *Redis) BLPop(ctx context.Context, timeout time.Duration, key string) (string, error) {
+	elems, err := r.client.BLPop(ctx, timeout, key).Result()
+	if err != nil {
+		return "", err
+	}
+
+	if len(elems) < 2 {
+		return "", errors.New("insufficient elements returned")
+	}
+	return elems[1], nil
+}
+
+func (r *Redis) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	return r.client.Expire(ctx, key, ttl).Err()
+}
+
+// The keys Redis tracks a list's leases in. They're only exported for tests and debugging; use Reliable to change them.
+func ProcessingKey(key, consumer string) string {
+	return key + ":processing:" + consumer
+}
+
+func LeasesKey(key string) string {
+	return key + ":inflight"
+}
+
+func LeaseOwnersKey(key string) string {
+	return key + ":inflight:owners"
+}
+
+func LeaseValuesKey(key string) string {
+	return key + ":inflight:values"
+}
+
+// claimScript moves the head of the list onto the consumer's processing list, and records the lease under its token.
+// Done in a script so a crash can never leave an element popped but untracked.
+var claimScript = redis.NewScript(`
+local raw = redis.call('LPOP', KEYS[1])
+if not raw then
+	return false
+end
+redis.call('RPUSH', KEYS[2], raw)
+redis.call('ZADD', KEYS[3], ARGV[1], ARGV[4])
+redis.call('HSET', KEYS[4], ARGV[4], ARGV[2])
+redis.call('HSET', KEYS[5], ARGV[4], raw)
+for i = 2, 5 do
+	redis.call('EXPIRE', KEYS[i], ARGV[3])
+end
+return raw
+`)
+
+var ackScript = redis.NewScript(`
+if redis.call('ZREM', KEYS[2], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('LREM', KEYS[1], 1, ARGV[2])
+redis.call('HDEL', KEYS[3], ARGV[1])
+redis.call('HDEL', KEYS[4], ARGV[1])
+return 1
+`)
+
+// requeueScript pushes one expired element back onto the front of the list, unless it was acked since it was found
+var requeueScript = redis.NewScript(`
+local deadline = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if not deadline or tonumber(deadline) > tonumber(ARGV[2]) then
+	return 0
+end
+local raw = redis.call('HGET', KEYS[3], ARGV[1])
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('HDEL', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[3], ARGV[1])
+if not raw then
+	return 0
+end
+redis.call('LREM', KEYS[5], 1, raw)
+redis.call('LPUSH', KEYS[4], raw)
+return 1
+`)
+
+func (r *Redis) Claim(ctx context.Context, key, consumer string, visibility time.Duration) (Lease, error) {
+	lease := Lease{Consumer: consumer, Token: newLeaseToken()}
+	deadline := time.Now().Add(visibility).UnixNano() / int64(time.Millisecond)
+	keys := []string{key, ProcessingKey(key, consumer), LeasesKey(key), LeaseOwnersKey(key), LeaseValuesKey(key)}
+	ttl := int64(LeaseTTL / time.Second)
+
+	var err error
+	lease.Value, err = claimScript.Run(ctx, r.client, keys, deadline, consumer, ttl, lease.Token).Text()
+	return lease, err
+}
+
+func (r *Redis) Ack(ctx context.Context, key string, lease Lease) error {
+	keys := []string{ProcessingKey(key, lease.Consumer), LeasesKey(key), LeaseOwnersKey(key), LeaseValuesKey(key)}
+	acked, err := ackScript.Run(ctx, r.client, keys, lease.Token, lease.Value).Int64()
+	if err != nil {
+		return err
+	}
+	if acked == 0 {
+		return ErrNotLeased
+	}
+	return nil
+}
+
+// Requeue finds the expired leases first, so each one's processing list can be declared to the script that requeues it
+func (r *Redis) Requeue(ctx context.Context, key string) (int64, error) {
+	now := strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)
+	tokens, err := r.client.ZRangeByScore(ctx, LeasesKey(key), &redis.ZRangeBy{Min: "-inf", Max: now}).Result()
+	if err != nil || len(tokens) == 0 {
+		return 0, err
+	}
+	owners, err := r.client.HMGet(ctx, LeaseOwnersKey(key), tokens...).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	// newest deadline first, so after each is pushed onto the front the oldest ends up at the head of the list
+	for i := len(tokens) - 1; i >= 0; i-- {
+		// without an owner there's no processing list to take the value back from, so requeueing would duplicate it.
+		// The lease is left to expire with the rest of the bookkeeping.
+		consumer, ok := owners[i].(string)
+		if !ok {
+			continue
+		}
+		keys := []string{LeasesKey(key), LeaseOwnersKey(key), LeaseValuesKey(key), key, ProcessingKey(key, consumer)}
+		requeued, err := requeueScript.Run(ctx, r.client, keys, tokens[i], now).Int64()
+		if err != nil {
+			return count, err
+		}
+		count += requeued
+	}
+	return count, nil
+}
+
+func (r *Redis) Publish(ctx context.Context, channel, message string) error {
+	return r.client.Publish(ctx, channel, message).Err()
+}
+
+func (r *Redis) Subscribe(ctx context.Context, channel string) (Subscription, error) {
+	ps := r.client.Subscribe(ctx, channel)
+	// wait for the subscription to be confirmed, otherwise messages published right after this returns can be missed
+	msg, err := ps.Receive(ctx)
+	if err != nil {
+		ps.Close()
+		return nil, err
+	}
+	if _, ok := msg.(*redis.Subscription); !ok {
+		ps.Close()
+		return nil, fmt.Errorf("unexpected reply subscribing to %s: %v", channel, msg)
+	}
+
+	sub := &redisSubscription{
+		ps:   ps,
+		ch:   make(chan string),
+		done: make(chan struct{}),
+	}
+	go sub.forward()
+	return sub, nil
+}
+
+type redisSubscription struct {
+	ps        *redis.PubSub
+	ch        chan string
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (s *redisSubscription) forward() {
+	defer close(s.ch)
+	for msg := range s.ps.Channel() {
+		select {
+		case s.ch <- msg.Payload:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *redisSubscription) Channel() <-chan string {
+	return s.ch
+}
+
+func (s *redisSubscription) Close() error {
+	s.closeOnce.Do(func() { close(s.done) })
+	return s.ps.Close()
+}
+
+var _ Broker = (*Redis)(nil)
@@ -16,3 +16,7 @@ func genericHash(s string) HashedID {
 	h.Write([]byte(s))
 	return HashedID(hex.EncodeToString(h.Sum(nil)))
 }
+
+func HashToken(token string) HashedID {
+	return genericHash(token)
+}
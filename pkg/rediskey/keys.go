@@ -1,5 +1,7 @@
 package rediskey
 
+import "strconv"
+
 const TotalGuildsSet = "automuteus:count:guilds"
 const ActiveGamesZSet = "automuteus:games"
 const EventsNamespace = "automuteus:capture:events"
@@ -59,6 +61,10 @@ func BotTokenIdentifyLock(token string) string {
 	return "automuteus:token:lock" + token
 }
 
+func BotTokenIdentifyBucketLock(token string, bucket int) string {
+	return BotTokenIdentifyLock(token) + ":" + strconv.Itoa(bucket)
+}
+
 func GuildSettings(id HashedID) string {
 	return "automuteus:settings:guild:" + string(id)
 }
@@ -67,6 +73,11 @@ func GuildTokenLock(guildID, hToken string) string {
 	return "automuteus:muterequest:lock:" + hToken + ":" + guildID
 }
 
+// TokenRateLimit exists while a bot token is waiting out a 429
+func TokenRateLimit(hToken string) string {
+	return "automuteus:ratelimit:token:" + hToken
+}
+
 func RoomCodesForConnCode(connCode string) string {
 	return "automuteus:roomcode:" + connCode
 }
@@ -90,3 +101,7 @@ func UserSoftban(userID string) string {
 func UserSoftbanCount(userID string) string {
 	return "automuteus:ratelimit:softban:count:user:" + userID
 }
+
+func EventsStream(connCode string) string {
+	return "automuteus:capture:stream:" + connCode
+}
@@ -0,0 +1,266 @@
+package settings
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/das08/utils/pkg/fuzzy"
+	"github.com/das08/utils/pkg/game"
+)
+
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatTOML Format = "toml"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown settings format")
+	ErrUnknownKey    = errors.New("unknown settings key")
+)
+
+// guildBoundKeys are settings that name users, roles or channels of one guild, so they mean nothing in another. They
+// are left out of exports, and ignored in imports.
+var guildBoundKeys = []string{"adminIDs", "permissionRoleIDs", "matchSummaryChannelID"}
+
+// Export writes the settings as a document that Import can read back, in any guild
+func Export(gs *GuildSettings, format Format) ([]byte, error) {
+	// go through a map so the guild-bound settings can be dropped, and TOML uses the same keys as JSON
+	m, err := toMap(gs)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range guildBoundKeys {
+		delete(m, key)
+	}
+
+	switch format {
+	case FormatJSON:
+		return json.MarshalIndent(m, "", "  ")
+	case FormatTOML:
+		buf := &bytes.Buffer{}
+		if err := toml.NewEncoder(buf).Encode(m); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+}
+
+// Import replaces gs's settings with a document written by Export, or by an older version of it. Settings the
+// document doesn't have go back to their defaults, apart from the guild-bound ones, which keep gs's values even if the
+// document has them. Keys that aren't settings are rejected (with suggestions, since they're most likely typos), as
+// are values the registry considers invalid. gs is only changed if the whole document is valid.
+func Import(gs *GuildSettings, data []byte, format Format) error {
+	var m map[string]interface{}
+	switch format {
+	case FormatJSON:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&m); err != nil {
+			return err
+		}
+	case FormatTOML:
+		if _, err := toml.Decode(string(data), &m); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+
+	if err := checkKeys(m, reflect.TypeOf(GuildSettings{}), ""); err != nil {
+		return err
+	}
+	current, err := toMap(gs)
+	if err != nil {
+		return err
+	}
+	for _, key := range guildBoundKeys {
+		m[key] = current[key]
+	}
+	normalized, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	imported, err := Unmarshal(normalized)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range Settings() {
+		if isGuildBound(s.Key) {
+			continue
+		}
+		if err := s.Check(s.Get(imported)); err != nil {
+			return err
+		}
+	}
+	gs.replace(imported)
+	return nil
+}
+
+func isGuildBound(key string) bool {
+	for _, k := range guildBoundKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func toMap(gs *GuildSettings) (map[string]interface{}, error) {
+	data, err := json.Marshal(gs)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	return intNumbers(m).(map[string]interface{}), nil
+}
+
+// intNumbers converts json.Numbers back to ints, so TOML doesn't write them as floats (or strings)
+func intNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = intNumbers(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = intNumbers(e)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
+
+// checkKeys makes sure every key in m is a JSON field of t, recursing into nested structs. Maps (like the delays for
+// each phase) can have any keys.
+func checkKeys(m map[string]interface{}, t reflect.Type, path string) error {
+	fields := jsonFields(t)
+	for key, v := range m {
+		field, ok := fields[key]
+		if !ok {
+			names := make([]string, 0, len(fields))
+			for name := range fields {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			err := fmt.Errorf("%w: %q", ErrUnknownKey, path+key)
+			if suggestions := fuzzy.Suggest(key, names); len(suggestions) > 0 {
+				err = fmt.Errorf("%w, did you mean %q?", err, path+suggestions[0])
+			}
+			return err
+		}
+		if nested, ok := v.(map[string]interface{}); ok && field.Kind() == reflect.Struct {
+			if err := checkKeys(nested, field, path+key+"."); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// Difference is a setting that isn't at its default. Keys are the registry's, like delays.tasks.discussion, and values
+// are rendered the way Apply reads them. Per-map delays are keyed like mapDelays.airship.lobby.tasks, with the delay
+// they override as the default, and voice rule overrides like voiceRules.channelOverrides.<channel ID>, as JSON.
+type Difference struct {
+	Key     string
+	Default string
+	Value   string
+}
+
+// Diff lists the settings that differ from MakeGuildSettings, sorted by key
+func Diff(gs *GuildSettings) []Difference {
+	snapshot := gs.Clone()
+	defaults := MakeGuildSettings()
+
+	var diffs []Difference
+	for _, s := range Settings() {
+		if def, value := s.Render(defaults), s.Render(snapshot); def != value {
+			diffs = append(diffs, Difference{Key: s.Key, Default: def, Value: value})
+		}
+	}
+	diffs = append(diffs, mapDelayDiffs(snapshot)...)
+	diffs = append(diffs, overrideDiffs("voiceRules.channelOverrides.", snapshot.VoiceRules.ChannelOverrides)...)
+	diffs = append(diffs, overrideDiffs("voiceRules.roleOverrides.", snapshot.VoiceRules.RoleOverrides)...)
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Key < diffs[j].Key
+	})
+	return diffs
+}
+
+func mapDelayDiffs(gs *GuildSettings) []Difference {
+	var diffs []Difference
+	for playMap, delays := range gs.Delays.MapDelays {
+		name, ok := game.MapNames[playMap]
+		if !ok {
+			name = strconv.Itoa(int(playMap))
+		}
+		for _, origin := range settingPhases {
+			for _, dest := range settingPhases {
+				if _, ok := delays[origin.ToString()][dest.ToString()]; !ok {
+					continue
+				}
+				def, value := gs.Delays.GetDuration(origin, dest), gs.Delays.GetDurationForMap(origin, dest, playMap)
+				if def != value {
+					diffs = append(diffs, Difference{
+						Key:     "mapDelays." + strings.ToLower(name) + "." + phaseKey(origin) + "." + phaseKey(dest),
+						Default: render(def),
+						Value:   render(value),
+					})
+				}
+			}
+		}
+	}
+	return diffs
+}
+
+func overrideDiffs(prefix string, overrides map[string]game.VoiceRuleOverride) []Difference {
+	var diffs []Difference
+	for id, o := range overrides {
+		data, err := json.Marshal(o)
+		if err != nil {
+			continue
+		}
+		diffs = append(diffs, Difference{Key: prefix + id, Value: string(data)})
+	}
+	return diffs
+}
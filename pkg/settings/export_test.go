@@ -0,0 +1,121 @@
+package settings
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/das08/utils/pkg/game"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	gs := MakeGuildSettings()
+	gs.SetLeaderboardSize(7)
+	gs.SetDelay(game.LOBBY, game.TASKS, 4)
+	gs.SetVoiceRule(true, game.DISCUSS, "dead", false)
+
+	for _, format := range []Format{FormatJSON, FormatTOML} {
+		data, err := Export(gs, format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		imported := MakeGuildSettings()
+		if err := Import(imported, data, format); err != nil {
+			t.Fatalf("%s: %v\n%s", format, err, data)
+		}
+		if !reflect.DeepEqual(imported.Clone(), gs.Clone()) {
+			t.Errorf("%s: settings changed in the round trip\n%s", format, data)
+		}
+	}
+}
+
+func TestExportSkipsGuildBoundSettings(t *testing.T) {
+	gs := MakeGuildSettings()
+	gs.SetAdminUserIDs([]string{"123"})
+	gs.SetPermissionRoleIDs([]string{"456"})
+	gs.SetMatchSummaryChannelID("789")
+
+	data, err := Export(gs, FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range guildBoundKeys {
+		if strings.Contains(string(data), key) {
+			t.Errorf("expected %s not to be exported\n%s", key, data)
+		}
+	}
+
+	// restoring a backup keeps the guild's own admins, roles and channel
+	existing := MakeGuildSettings()
+	existing.SetAdminUserIDs([]string{"141101495071408128"})
+	existing.SetLeaderboardSize(9)
+	err = Import(existing, []byte(`{"adminIDs": ["123"], "permissionRoleIDs": ["456"], "matchSummaryChannelID": "789", "leaderboardSize": 4}`), FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := existing.GetAdminUserIDs(); len(ids) != 1 || ids[0] != "141101495071408128" {
+		t.Errorf("expected the guild's admins to be kept, got %v", ids)
+	}
+	if len(existing.GetPermissionRoleIDs()) != 0 || existing.GetMatchSummaryChannelID() != "" {
+		t.Errorf("expected guild-bound settings in the document to be ignored, got %+v", existing)
+	}
+	if existing.GetLeaderboardSize() != 4 {
+		t.Errorf("expected the imported leaderboard size, got %d", existing.GetLeaderboardSize())
+	}
+}
+
+func TestImportRejects(t *testing.T) {
+	tests := []struct {
+		name, data string
+		format     Format
+		err        error
+		suggestion string
+	}{
+		{"typo", `{"leaderbordSize": 3}`, FormatJSON, ErrUnknownKey, `"leaderboardSize"`},
+		{"nested typo", "[delays]\n[delays.delayz]\n", FormatTOML, ErrUnknownKey, `"delays.delays"`},
+		{"unknown", `{"favouriteColor": "red"}`, FormatJSON, ErrUnknownKey, ""},
+		{"out of range", "leaderboardSize = 50\n", FormatTOML, ErrInvalidValue, ""},
+		{"bad format", `{}`, Format("yaml"), ErrUnknownFormat, ""},
+	}
+	for _, test := range tests {
+		gs := MakeGuildSettings()
+		gs.SetLeaderboardSize(7)
+		err := Import(gs, []byte(test.data), test.format)
+		if gs.GetLeaderboardSize() != 7 {
+			t.Errorf("%s: expected a rejected import not to change the settings", test.name)
+		}
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+			continue
+		}
+		if test.suggestion != "" && !strings.Contains(err.Error(), test.suggestion) {
+			t.Errorf("%s: expected the error to suggest %s, got %q", test.name, test.suggestion, err)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	gs := MakeGuildSettings()
+	if diffs := Diff(gs); len(diffs) != 0 {
+		t.Errorf("expected the defaults not to differ, got %v", diffs)
+	}
+
+	gs.SetMuteSpectator(true)
+	gs.SetDelay(game.TASKS, game.DISCUSS, 2)
+	gs.SetVoiceRule(false, game.LOBBY, "alive", true)
+	gs.SetDelayForMap(game.LOBBY, game.TASKS, game.AIRSHIP, 9)
+	gs.SetDelayForMap(game.LOBBY, game.DISCUSS, game.AIRSHIP, 0)
+	gs.SetChannelVoiceRule("141101495071408128", true, game.TASKS, "alive", false)
+	diffs := Diff(gs)
+	expected := []Difference{
+		{Key: "delays.tasks.discussion", Default: "0", Value: "2"},
+		{Key: "mapDelays.airship.lobby.tasks", Default: "7", Value: "9"},
+		{Key: "muteSpectator", Default: "false", Value: "true"},
+		{Key: "voiceRules.channelOverrides.141101495071408128", Value: `{"MuteRules":{"TASKS":{"alive":false}}}`},
+		{Key: "voiceRules.deaf.lobby.alive", Default: "false", Value: "true"},
+	}
+	if !reflect.DeepEqual(diffs, expected) {
+		t.Errorf("expected %v, got %v", expected, diffs)
+	}
+}
@@ -1,7 +1,9 @@
 package settings
 
 import (
+	"encoding/json"
 	"sync"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/das08/utils/pkg/game"
@@ -11,7 +13,10 @@ import (
 const DefaultLeaderboardSize = 3
 const DefaultLeaderboardMin = 3
 
+// GuildSettings setters store whatever they're given. Values from users should go through Apply, which validates them
+// against the registry first.
 type GuildSettings struct {
+	Version                  int             `json:"version"`
 	AdminUserIDs             []string        `json:"adminIDs"`
 	PermissionRoleIDs        []string        `json:"permissionRoleIDs"`
 	Language                 string          `json:"language"`
@@ -32,6 +37,7 @@ type GuildSettings struct {
 
 func MakeGuildSettings() *GuildSettings {
 	return &GuildSettings{
+		Version:                  CurrentVersion,
 		Language:                 locale.DefaultLang,
 		AdminUserIDs:             []string{},
 		PermissionRoleIDs:        []string{},
@@ -61,6 +67,8 @@ func (gs *GuildSettings) HasAdminPerms(user *discordgo.User) bool {
 		return false
 	}
 
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
 	for _, v := range gs.AdminUserIDs {
 		if v == user.ID {
 			return true
@@ -70,6 +78,8 @@ func (gs *GuildSettings) HasAdminPerms(user *discordgo.User) bool {
 }
 
 func (gs *GuildSettings) HasRolePerms(mem *discordgo.Member) bool {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
 	for _, role := range mem.Roles {
 		for _, testRole := range gs.PermissionRoleIDs {
 			if testRole == role {
@@ -81,58 +91,86 @@ func (gs *GuildSettings) HasRolePerms(mem *discordgo.Member) bool {
 }
 
 func (gs *GuildSettings) GetAdminUserIDs() []string {
-	return gs.AdminUserIDs
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
+	return copyIDs(gs.AdminUserIDs)
 }
 
 func (gs *GuildSettings) SetAdminUserIDs(ids []string) {
-	gs.AdminUserIDs = ids
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	gs.AdminUserIDs = copyIDs(ids)
 }
 
 func (gs *GuildSettings) GetPermissionRoleIDs() []string {
-	return gs.PermissionRoleIDs
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
+	return copyIDs(gs.PermissionRoleIDs)
 }
 
 func (gs *GuildSettings) SetPermissionRoleIDs(ids []string) {
-	gs.PermissionRoleIDs = ids
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	gs.PermissionRoleIDs = copyIDs(ids)
 }
 
 func (gs *GuildSettings) GetUnmuteDeadDuringTasks() bool {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
 	return gs.UnmuteDeadDuringTasks
 }
 
 func (gs *GuildSettings) GetDeleteGameSummaryMinutes() int {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
 	return gs.DeleteGameSummaryMinutes
 }
 
 func (gs *GuildSettings) SetDeleteGameSummaryMinutes(num int) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
 	gs.DeleteGameSummaryMinutes = num
 }
 
 func (gs *GuildSettings) SetMatchSummaryChannelID(id string) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
 	gs.MatchSummaryChannelID = id
 }
 
 func (gs *GuildSettings) GetMatchSummaryChannelID() string {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
 	return gs.MatchSummaryChannelID
 }
 
 func (gs *GuildSettings) GetAutoRefresh() bool {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
 	return gs.AutoRefresh
 }
 
 func (gs *GuildSettings) SetAutoRefresh(n bool) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
 	gs.AutoRefresh = n
 }
 
 func (gs *GuildSettings) GetLeaderboardMention() bool {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
 	return gs.LeaderboardMention
 }
 
 func (gs *GuildSettings) SetLeaderboardMention(v bool) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
 	gs.LeaderboardMention = v
 }
 
 func (gs *GuildSettings) GetLeaderboardSize() int {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
 	if gs.LeaderboardSize < 1 {
 		return DefaultLeaderboardSize
 	}
@@ -140,10 +178,14 @@ func (gs *GuildSettings) GetLeaderboardSize() int {
 }
 
 func (gs *GuildSettings) SetLeaderboardSize(v int) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
 	gs.LeaderboardSize = v
 }
 
 func (gs *GuildSettings) GetLeaderboardMin() int {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
 	if gs.LeaderboardMin < 1 {
 		return DefaultLeaderboardMin
 	}
@@ -151,22 +193,32 @@ func (gs *GuildSettings) GetLeaderboardMin() int {
 }
 
 func (gs *GuildSettings) SetLeaderboardMin(v int) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
 	gs.LeaderboardMin = v
 }
 
 func (gs *GuildSettings) GetMuteSpectator() bool {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
 	return gs.MuteSpectator
 }
 
 func (gs *GuildSettings) SetMuteSpectator(behavior bool) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
 	gs.MuteSpectator = behavior
 }
 
 func (gs *GuildSettings) GetMapDetailed() bool {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
 	return gs.MapVersion == "detailed"
 }
 
 func (gs *GuildSettings) SetMapDetailed(v bool) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
 	if v {
 		gs.MapVersion = "detailed"
 	} else {
@@ -175,26 +227,120 @@ func (gs *GuildSettings) SetMapDetailed(v bool) {
 }
 
 func (gs *GuildSettings) SetUnmuteDeadDuringTasks(v bool) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
 	gs.UnmuteDeadDuringTasks = v
 }
 
 func (gs *GuildSettings) GetLanguage() string {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
 	return gs.Language
 }
 
 func (gs *GuildSettings) SetLanguage(l string) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
 	gs.Language = l
 }
 
+// GetDelay is the delay before the map is known, which is EMPTYMAP's. Use GetDelayForMap once the lobby has a map, so
+// its overrides apply.
 func (gs *GuildSettings) GetDelay(oldPhase, newPhase game.Phase) int {
-	return gs.Delays.GetDelay(oldPhase, newPhase)
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
+	return gs.Delays.GetDelayForMap(oldPhase, newPhase, game.EMPTYMAP)
 }
 
 func (gs *GuildSettings) SetDelay(oldPhase, newPhase game.Phase, v int) {
-	gs.Delays.Delays[oldPhase.ToString()][newPhase.ToString()] = v
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	gs.Delays.SetDelay(oldPhase, newPhase, v)
+}
+
+// GetDuration is GetDelay as a time.Duration
+func (gs *GuildSettings) GetDuration(oldPhase, newPhase game.Phase) time.Duration {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
+	return gs.Delays.GetDurationForMap(oldPhase, newPhase, game.EMPTYMAP)
+}
+
+func (gs *GuildSettings) SetDuration(oldPhase, newPhase game.Phase, d time.Duration) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	gs.Delays.SetDuration(oldPhase, newPhase, d)
+}
+
+func (gs *GuildSettings) GetDurationForMap(oldPhase, newPhase game.Phase, playMap game.PlayMap) time.Duration {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
+	return gs.Delays.GetDurationForMap(oldPhase, newPhase, playMap)
+}
+
+func (gs *GuildSettings) SetDurationForMap(oldPhase, newPhase game.Phase, playMap game.PlayMap, d time.Duration) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	gs.Delays.SetDurationForMap(oldPhase, newPhase, playMap, d)
+}
+
+func (gs *GuildSettings) GetDelayStagger() (stagger, jitter time.Duration) {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
+	return gs.Delays.Stagger.Duration(), gs.Delays.Jitter.Duration()
+}
+
+func (gs *GuildSettings) SetDelayStagger(stagger, jitter time.Duration) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	gs.Delays.Stagger = game.Delay(stagger)
+	gs.Delays.Jitter = game.Delay(jitter)
+}
+
+func (gs *GuildSettings) SetStagger(stagger time.Duration) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	gs.Delays.Stagger = game.Delay(stagger)
+}
+
+func (gs *GuildSettings) SetJitter(jitter time.Duration) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	gs.Delays.Jitter = game.Delay(jitter)
+}
+
+func (gs *GuildSettings) ScheduleVoiceChanges(oldPhase, newPhase game.Phase, playMap game.PlayMap, userIDs []string, now time.Time) []game.ScheduledChange {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
+	return gs.Delays.Schedule(oldPhase, newPhase, playMap, userIDs, now)
+}
+
+func (gs *GuildSettings) GetDelayForMap(oldPhase, newPhase game.Phase, playMap game.PlayMap) int {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
+	return gs.Delays.GetDelayForMap(oldPhase, newPhase, playMap)
+}
+
+func (gs *GuildSettings) LookupDelay(oldPhase, newPhase game.Phase, playMap game.PlayMap) (time.Duration, error) {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
+	return gs.Delays.Lookup(oldPhase, newPhase, playMap)
+}
+
+func (gs *GuildSettings) SetDelayForMap(oldPhase, newPhase game.Phase, playMap game.PlayMap, v int) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	gs.Delays.SetDelayForMap(oldPhase, newPhase, playMap, v)
+}
+
+func (gs *GuildSettings) ClearMapDelays(playMap game.PlayMap) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	gs.Delays.ClearMapDelays(playMap)
 }
 
 func (gs *GuildSettings) GetVoiceRule(isMute bool, phase game.Phase, alive string) bool {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
 	if isMute {
 		return gs.VoiceRules.MuteRules[phase.ToString()][alive]
 	}
@@ -202,17 +348,74 @@ func (gs *GuildSettings) GetVoiceRule(isMute bool, phase game.Phase, alive strin
 }
 
 func (gs *GuildSettings) SetVoiceRule(isMute bool, phase game.Phase, alive string, val bool) {
-	if isMute {
-		gs.VoiceRules.MuteRules[phase.ToString()][alive] = val
-	}
-	gs.VoiceRules.DeafRules[phase.ToString()][alive] = val
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	gs.VoiceRules.SetRule(isMute, phase, alive, val)
+}
+
+func (gs *GuildSettings) GetPlayerVoiceRule(phase game.Phase, state game.PlayerState) game.VoiceState {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
+	return gs.VoiceRules.Get(phase, state)
+}
+
+func (gs *GuildSettings) SetPlayerVoiceRule(phase game.Phase, state game.PlayerState, vs game.VoiceState) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	gs.VoiceRules.Set(phase, state, vs)
 }
 
 func (gs *GuildSettings) GetVoiceState(alive bool, tracked bool, phase game.Phase) (bool, bool) {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
 	return gs.VoiceRules.GetVoiceState(alive, tracked, phase)
 }
 
+// ResolveVoiceState is GetVoiceState, taking the player's voice channel and role overrides, and spectators, into account
+func (gs *GuildSettings) ResolveVoiceState(ctx game.VoiceContext) (bool, bool) {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
+	ctx.MuteSpectator = gs.MuteSpectator
+	return gs.VoiceRules.Resolve(ctx)
+}
+
+func (gs *GuildSettings) SetChannelVoiceRule(channelID string, isMute bool, phase game.Phase, alive string, val bool) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	if gs.VoiceRules.ChannelOverrides == nil {
+		gs.VoiceRules.ChannelOverrides = map[string]game.VoiceRuleOverride{}
+	}
+	o := gs.VoiceRules.ChannelOverrides[channelID]
+	o.SetRule(isMute, phase, alive, val)
+	gs.VoiceRules.ChannelOverrides[channelID] = o
+}
+
+func (gs *GuildSettings) SetRoleVoiceRule(roleID string, isMute bool, phase game.Phase, alive string, val bool) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	if gs.VoiceRules.RoleOverrides == nil {
+		gs.VoiceRules.RoleOverrides = map[string]game.VoiceRuleOverride{}
+	}
+	o := gs.VoiceRules.RoleOverrides[roleID]
+	o.SetRule(isMute, phase, alive, val)
+	gs.VoiceRules.RoleOverrides[roleID] = o
+}
+
+func (gs *GuildSettings) ClearChannelVoiceRules(channelID string) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	delete(gs.VoiceRules.ChannelOverrides, channelID)
+}
+
+func (gs *GuildSettings) ClearRoleVoiceRules(roleID string) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	delete(gs.VoiceRules.RoleOverrides, roleID)
+}
+
 func (gs *GuildSettings) GetDisplayRoomCode() string {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
 	if gs.DisplayRoomCode == "" {
 		return "always"
 	}
@@ -220,5 +423,75 @@ func (gs *GuildSettings) GetDisplayRoomCode() string {
 }
 
 func (gs *GuildSettings) SetDisplayRoomCode(r string) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
 	gs.DisplayRoomCode = r
 }
+
+// Clone returns a deep copy, so the caller can read a consistent snapshot without holding the lock
+func (gs *GuildSettings) Clone() *GuildSettings {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
+	return &GuildSettings{
+		Version:                  gs.Version,
+		AdminUserIDs:             copyIDs(gs.AdminUserIDs),
+		PermissionRoleIDs:        copyIDs(gs.PermissionRoleIDs),
+		Language:                 gs.Language,
+		VoiceRules:               gs.VoiceRules.Clone(),
+		MapVersion:               gs.MapVersion,
+		Delays:                   gs.Delays.Clone(),
+		DeleteGameSummaryMinutes: gs.DeleteGameSummaryMinutes,
+		UnmuteDeadDuringTasks:    gs.UnmuteDeadDuringTasks,
+		AutoRefresh:              gs.AutoRefresh,
+		MatchSummaryChannelID:    gs.MatchSummaryChannelID,
+		LeaderboardMention:       gs.LeaderboardMention,
+		LeaderboardSize:          gs.LeaderboardSize,
+		LeaderboardMin:           gs.LeaderboardMin,
+		MuteSpectator:            gs.MuteSpectator,
+		DisplayRoomCode:          gs.DisplayRoomCode,
+	}
+}
+
+// replace sets every field of gs to from's
+func (gs *GuildSettings) replace(from *GuildSettings) {
+	c := from.Clone()
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	gs.Version = c.Version
+	gs.AdminUserIDs = c.AdminUserIDs
+	gs.PermissionRoleIDs = c.PermissionRoleIDs
+	gs.Language = c.Language
+	gs.VoiceRules = c.VoiceRules
+	gs.MapVersion = c.MapVersion
+	gs.Delays = c.Delays
+	gs.DeleteGameSummaryMinutes = c.DeleteGameSummaryMinutes
+	gs.UnmuteDeadDuringTasks = c.UnmuteDeadDuringTasks
+	gs.AutoRefresh = c.AutoRefresh
+	gs.MatchSummaryChannelID = c.MatchSummaryChannelID
+	gs.LeaderboardMention = c.LeaderboardMention
+	gs.LeaderboardSize = c.LeaderboardSize
+	gs.LeaderboardMin = c.LeaderboardMin
+	gs.MuteSpectator = c.MuteSpectator
+	gs.DisplayRoomCode = c.DisplayRoomCode
+}
+
+// plainGuildSettings has the same fields but none of the methods, so (un)marshalling it doesn't recurse
+type plainGuildSettings GuildSettings
+
+func (gs *GuildSettings) MarshalJSON() ([]byte, error) {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
+	return json.Marshal((*plainGuildSettings)(gs))
+}
+
+func (gs *GuildSettings) UnmarshalJSON(data []byte) error {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	return json.Unmarshal(data, (*plainGuildSettings)(gs))
+}
+
+func copyIDs(ids []string) []string {
+	c := make([]string, len(ids))
+	copy(c, ids)
+	return c
+}
@@ -0,0 +1,179 @@
+package settings
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/das08/utils/pkg/game"
+)
+
+// run with -race; the assertions matter less than the detector seeing every accessor at once
+func TestGuildSettingsConcurrentAccess(t *testing.T) {
+	gs := MakeGuildSettings()
+	user := &discordgo.User{ID: "1"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				gs.SetDelay(game.LOBBY, game.TASKS, j)
+				gs.SetVoiceRule(true, game.TASKS, "dead", j%2 == 0)
+				gs.SetAdminUserIDs([]string{"1", "2"})
+				gs.SetLeaderboardSize(i + 1)
+				gs.SetLanguage("en")
+
+				_ = gs.GetDelay(game.LOBBY, game.TASKS)
+				_ = gs.GetVoiceRule(true, game.TASKS, "dead")
+				_, _ = gs.GetVoiceState(false, true, game.TASKS)
+				_ = gs.HasAdminPerms(user)
+				_ = gs.GetLeaderboardSize()
+				_ = gs.Clone()
+				if _, err := json.Marshal(gs); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestGuildSettingsClone(t *testing.T) {
+	gs := MakeGuildSettings()
+	gs.SetAdminUserIDs([]string{"1"})
+	gs.SetDelay(game.LOBBY, game.TASKS, 2)
+
+	c := gs.Clone()
+	c.SetDelay(game.LOBBY, game.TASKS, 7)
+	c.SetVoiceRule(true, game.TASKS, "alive", false)
+	c.AdminUserIDs[0] = "2"
+
+	if gs.GetDelay(game.LOBBY, game.TASKS) != 2 {
+		t.Error("changing a clone's delays changed the original")
+	}
+	if !gs.GetVoiceRule(true, game.TASKS, "alive") {
+		t.Error("changing a clone's voice rules changed the original")
+	}
+	if gs.GetAdminUserIDs()[0] != "1" {
+		t.Error("changing a clone's admins changed the original")
+	}
+}
+
+func TestGuildSettingsNestedWritesOnEmptyMaps(t *testing.T) {
+	gs := &GuildSettings{}
+	gs.SetDelay(game.LOBBY, game.TASKS, 3)
+	gs.SetVoiceRule(true, game.DISCUSS, "dead", true)
+
+	if v := gs.GetDelay(game.LOBBY, game.TASKS); v != 3 {
+		t.Errorf("expected delay 3, got %d", v)
+	}
+	if !gs.GetVoiceRule(true, game.DISCUSS, "dead") {
+		t.Error("expected the mute rule to be set")
+	}
+}
+
+func TestGuildSettingsVoiceOverrides(t *testing.T) {
+	gs := MakeGuildSettings()
+	gs.SetRoleVoiceRule("streamer", false, game.TASKS, "alive", false)
+	gs.SetChannelVoiceRule("lobby2", true, game.TASKS, "alive", false)
+
+	data, err := json.Marshal(gs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mute, deaf := loaded.ResolveVoiceState(game.VoiceContext{
+		Alive: true, Tracked: true, Phase: game.TASKS, ChannelID: "lobby2", RoleIDs: []string{"streamer"},
+	})
+	if mute || deaf {
+		t.Errorf("expected the overrides to survive saving, got mute %v deaf %v", mute, deaf)
+	}
+
+	loaded.ClearRoleVoiceRules("streamer")
+	if _, deaf := loaded.ResolveVoiceState(game.VoiceContext{Alive: true, Tracked: true, Phase: game.TASKS, RoleIDs: []string{"streamer"}}); !deaf {
+		t.Error("expected the guild rule once the role override is cleared")
+	}
+}
+
+func TestGuildSettingsSetVoiceRuleOnlyChangesOneRule(t *testing.T) {
+	tests := []struct {
+		isMute   bool
+		expected game.VoiceState
+	}{
+		{true, game.VoiceState{Mute: true, Deaf: false}},
+		{false, game.VoiceState{Mute: false, Deaf: true}},
+	}
+	for _, test := range tests {
+		gs := MakeGuildSettings()
+		gs.SetVoiceRule(test.isMute, game.LOBBY, "dead", true)
+		if got := gs.GetPlayerVoiceRule(game.LOBBY, game.Dead); got != test.expected {
+			t.Errorf("isMute=%v: expected %+v, got %+v", test.isMute, test.expected, got)
+		}
+	}
+}
+
+func TestGuildSettingsSpectatorRulesStayCompatible(t *testing.T) {
+	// settings saved before spectator rules existed only have alive and dead
+	old := `{"version":2,"muteSpectator":true,"voiceRules":{"MuteRules":{"DISCUSSION":{"alive":false,"dead":true}},"DeafRules":{"DISCUSSION":{"alive":false,"dead":false}}}}`
+	gs, err := Unmarshal([]byte(old))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mute, _ := gs.ResolveVoiceState(game.VoiceContext{Phase: game.DISCUSS, State: game.Spectator}); !mute {
+		t.Error("expected spectators to follow MuteSpectator without a spectator rule")
+	}
+
+	gs.SetPlayerVoiceRule(game.DISCUSS, game.Spectator, game.VoiceState{})
+	data, err := json.Marshal(gs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs, err = Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mute, _ := gs.ResolveVoiceState(game.VoiceContext{Phase: game.DISCUSS, State: game.Spectator}); mute {
+		t.Error("expected the saved spectator rule to win over MuteSpectator")
+	}
+	if gs.VoiceRules.MuteRules["DISCUSSION"]["spectator"] || !gs.VoiceRules.MuteRules["DISCUSSION"]["dead"] {
+		t.Errorf("expected spectator rules alongside the dead ones, got %v", gs.VoiceRules.MuteRules)
+	}
+}
+
+func TestGuildSettingsMapDelaysClone(t *testing.T) {
+	gs := MakeGuildSettings()
+	gs.SetDelayForMap(game.LOBBY, game.TASKS, game.AIRSHIP, 9)
+
+	c := gs.Clone()
+	c.SetDelayForMap(game.LOBBY, game.TASKS, game.AIRSHIP, 1)
+	if d := gs.GetDelayForMap(game.LOBBY, game.TASKS, game.AIRSHIP); d != 9 {
+		t.Errorf("changing a clone's map delays changed the original, got %d", d)
+	}
+	if d := gs.GetDelayForMap(game.LOBBY, game.TASKS, game.SKELD); d != gs.GetDelay(game.LOBBY, game.TASKS) {
+		t.Errorf("expected maps without overrides to use the guild's delay, got %d", d)
+	}
+
+	gs.SetDelayForMap(game.LOBBY, game.TASKS, game.EMPTYMAP, 4)
+	if d := gs.GetDelay(game.LOBBY, game.TASKS); d != 4 {
+		t.Errorf("expected GetDelay to resolve through the map overrides, got %d", d)
+	}
+}
+
+func TestUnmarshalOutOfRangeStagger(t *testing.T) {
+	gs, err := Unmarshal([]byte(`{"version": 2, "delays": {"delays": {}, "stagger": 30, "jitter": "1m"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stagger, jitter := gs.GetDelayStagger(); stagger != time.Second*30 || jitter != time.Minute {
+		t.Errorf("expected the stored stagger and jitter, got %s and %s", stagger, jitter)
+	}
+}
@@ -0,0 +1,91 @@
+package settings
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// CurrentVersion is the version of the GuildSettings JSON this code writes. Settings saved before versioning have no
+// version field, and count as version 0.
+//
+//	0: unversioned. leaderboardMin may be missing, and leaderboardSize stored as 0 to mean the default.
+//	1: leaderboard sizes are always stored explicitly. mapVersion and displayRoomCode may be missing or empty.
+//	2: mapVersion is always "simple" or "detailed", and displayRoomCode is always set.
+const CurrentVersion = 2
+
+var ErrUnknownVersion = errors.New("guild settings were saved by a newer version")
+
+// migrations[i] upgrades settings from version i to version i+1
+var migrations = []func(map[string]interface{}){
+	migrateLeaderboard,
+	migrateDisplay,
+}
+
+func migrateLeaderboard(m map[string]interface{}) {
+	if n, ok := intField(m, "leaderboardSize"); !ok || n < 1 {
+		m["leaderboardSize"] = DefaultLeaderboardSize
+	}
+	if n, ok := intField(m, "leaderboardMin"); !ok || n < 1 {
+		m["leaderboardMin"] = DefaultLeaderboardMin
+	}
+}
+
+func migrateDisplay(m map[string]interface{}) {
+	if v, _ := m["mapVersion"].(string); v != "detailed" {
+		m["mapVersion"] = "simple"
+	}
+	if v, _ := m["displayRoomCode"].(string); v == "" {
+		m["displayRoomCode"] = "always"
+	}
+}
+
+func intField(m map[string]interface{}, key string) (int, bool) {
+	num, ok := m[key].(json.Number)
+	if !ok {
+		return 0, false
+	}
+	n, err := num.Int64()
+	return int(n), err == nil
+}
+
+// Migrate upgrades stored GuildSettings JSON of any version to CurrentVersion, one version at a time
+func Migrate(data []byte) ([]byte, error) {
+	m := map[string]interface{}{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+
+	version := 0
+	if _, present := m["version"]; present {
+		v, ok := intField(m, "version")
+		if !ok || v < 0 {
+			return nil, fmt.Errorf("invalid guild settings version %v", m["version"])
+		}
+		version = v
+	}
+	if version > CurrentVersion {
+		return nil, fmt.Errorf("%w: version %d, but only up to %d is supported", ErrUnknownVersion, version, CurrentVersion)
+	}
+	if version == CurrentVersion {
+		return data, nil
+	}
+
+	for ; version < CurrentVersion; version++ {
+		migrations[version](m)
+	}
+	m["version"] = CurrentVersion
+	return json.Marshal(m)
+}
+
+// Unmarshal decodes stored GuildSettings of any version
+func Unmarshal(data []byte) (*GuildSettings, error) {
+	migrated, err := Migrate(data)
+	if err != nil {
+		return nil, err
+	}
+	return decodeGuildSettings(migrated)
+}
@@ -0,0 +1,82 @@
+package settings
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestMigrateGolden loads settings saved in each historical format, and compares what they decode to against the
+// matching .golden.json file. Run with -update to regenerate the golden files after an intentional change.
+func TestMigrateGolden(t *testing.T) {
+	inputs, err := filepath.Glob("testdata/migrate/v*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range inputs {
+		if strings.HasSuffix(input, ".golden.json") {
+			continue
+		}
+		data, err := ioutil.ReadFile(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gs, err := Unmarshal(data)
+		if err != nil {
+			t.Errorf("%s: %v", input, err)
+			continue
+		}
+		if gs.Version != CurrentVersion {
+			t.Errorf("%s: expected version %d after migrating, got %d", input, CurrentVersion, gs.Version)
+		}
+		actual, err := json.MarshalIndent(gs, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		golden := strings.TrimSuffix(input, ".json") + ".golden.json"
+		if *update {
+			if err := ioutil.WriteFile(golden, append(actual, '\n'), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		expected, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(bytes.TrimSpace(expected), actual) {
+			t.Errorf("%s: migrated settings don't match %s:\n%s", input, golden, actual)
+		}
+	}
+}
+
+func TestMigrateIsIdempotent(t *testing.T) {
+	data, err := json.Marshal(MakeGuildSettings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrated, err := Migrate(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, migrated) {
+		t.Error("current settings should not be changed by migrating")
+	}
+}
+
+func TestMigrateRejectsFutureVersions(t *testing.T) {
+	if _, err := Migrate([]byte(`{"version": 99}`)); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("expected ErrUnknownVersion, got %v", err)
+	}
+	if _, err := Migrate([]byte(`{"version": "two"}`)); err == nil {
+		t.Error("expected an error for a non-numeric version")
+	}
+}
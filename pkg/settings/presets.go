@@ -0,0 +1,121 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/das08/utils/pkg/fuzzy"
+	"github.com/das08/utils/pkg/game"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+var ErrUnknownPreset = errors.New("unknown voice preset")
+
+// VoicePreset is a named combination of voice rules and the settings that go with them
+type VoicePreset struct {
+	Name                  string
+	Description           *i18n.Message
+	UnmuteDeadDuringTasks bool
+	MuteSpectator         bool
+	// Rules builds a fresh copy of the preset's rules, so applying a preset never shares its maps
+	Rules func() game.VoiceRules
+}
+
+var VoicePresets = []*VoicePreset{
+	{
+		Name:        "classic",
+		Description: &i18n.Message{ID: "settings.presets.classic.Description", Other: "The default: the living are deafened during tasks, and the dead are muted during discussions"},
+		Rules:       game.MakeMuteAndDeafenRules,
+	},
+	{
+		Name:                  "dead-chat",
+		Description:           &i18n.Message{ID: "settings.presets.deadChat.Description", Other: "Like classic, but the dead can talk to each other during tasks"},
+		UnmuteDeadDuringTasks: true,
+		Rules:                 game.MakeMuteAndDeafenRules,
+	},
+	{
+		Name:        "spectator-friendly",
+		Description: &i18n.Message{ID: "settings.presets.spectatorFriendly.Description", Other: "Like classic, but spectators can talk during tasks, and are muted during discussions so they can't give anything away"},
+		Rules: func() game.VoiceRules {
+			rules := game.MakeMuteAndDeafenRules()
+			rules.Set(game.LOBBY, game.Spectator, game.VoiceState{})
+			rules.Set(game.TASKS, game.Spectator, game.VoiceState{})
+			rules.Set(game.DISCUSS, game.Spectator, game.VoiceState{Mute: true})
+			return rules
+		},
+	},
+	{
+		Name:          "hardcore",
+		Description:   &i18n.Message{ID: "settings.presets.hardcore.Description", Other: "The dead are deafened during discussions too, and spectators are treated like the dead"},
+		MuteSpectator: true,
+		Rules: func() game.VoiceRules {
+			rules := game.MakeMuteAndDeafenRules()
+			rules.Set(game.DISCUSS, game.Dead, game.VoiceState{Mute: true, Deaf: true})
+			return rules
+		},
+	},
+}
+
+func LookupVoicePreset(name string) (*VoicePreset, error) {
+	names := make([]string, len(VoicePresets))
+	for i, p := range VoicePresets {
+		if p.Name == name {
+			return p, nil
+		}
+		names[i] = p.Name
+	}
+	err := fmt.Errorf("%w: %q", ErrUnknownPreset, name)
+	if suggestions := fuzzy.Suggest(name, names); len(suggestions) > 0 {
+		err = fmt.Errorf("%w, did you mean %q?", err, suggestions[0])
+	}
+	return nil, err
+}
+
+// ApplyVoicePreset replaces the guild's voice rules with the preset's. Channel and role overrides are kept.
+func (gs *GuildSettings) ApplyVoicePreset(name string) error {
+	p, err := LookupVoicePreset(name)
+	if err != nil {
+		return err
+	}
+	rules := p.Rules()
+
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	gs.VoiceRules.MuteRules = rules.MuteRules
+	gs.VoiceRules.DeafRules = rules.DeafRules
+	gs.UnmuteDeadDuringTasks = p.UnmuteDeadDuringTasks
+	gs.MuteSpectator = p.MuteSpectator
+	return nil
+}
+
+// VoicePreset returns the name of the preset the guild's voice rules behave the same as, if any. Channel and role
+// overrides aren't compared.
+func (gs *GuildSettings) VoicePreset() (string, bool) {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
+	for _, p := range VoicePresets {
+		if gs.UnmuteDeadDuringTasks != p.UnmuteDeadDuringTasks {
+			continue
+		}
+		rules := p.Rules()
+		if sameVoiceRules(&gs.VoiceRules, gs.MuteSpectator, &rules, p.MuteSpectator) {
+			return p.Name, true
+		}
+	}
+	return "", false
+}
+
+// sameVoiceRules compares what the rules decide for every phase and player state, so a rule that's missing and one
+// that's explicitly false count as the same
+func sameVoiceRules(a *game.VoiceRules, aSpectator bool, b *game.VoiceRules, bSpectator bool) bool {
+	for _, phase := range []game.Phase{game.LOBBY, game.TASKS, game.DISCUSS} {
+		for _, state := range game.PlayerStates {
+			aMute, aDeaf := a.Resolve(game.VoiceContext{Phase: phase, State: state, MuteSpectator: aSpectator})
+			bMute, bDeaf := b.Resolve(game.VoiceContext{Phase: phase, State: state, MuteSpectator: bSpectator})
+			if aMute != bMute || aDeaf != bDeaf {
+				return false
+			}
+		}
+	}
+	return true
+}
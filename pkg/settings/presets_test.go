@@ -0,0 +1,51 @@
+package settings
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/das08/utils/pkg/game"
+)
+
+func TestVoicePresets(t *testing.T) {
+	gs := MakeGuildSettings()
+	if name, ok := gs.VoicePreset(); !ok || name != "classic" {
+		t.Errorf("expected the defaults to be classic, got %q", name)
+	}
+
+	gs.SetRoleVoiceRule("streamer", false, game.TASKS, "alive", false)
+	for _, p := range VoicePresets {
+		if err := gs.ApplyVoicePreset(p.Name); err != nil {
+			t.Fatal(err)
+		}
+		if name, ok := gs.VoicePreset(); !ok || name != p.Name {
+			t.Errorf("expected %s to be detected after applying it, got %q", p.Name, name)
+		}
+		if _, ok := gs.VoiceRules.RoleOverrides["streamer"]; !ok {
+			t.Errorf("applying %s dropped the role overrides", p.Name)
+		}
+	}
+
+	gs.SetVoiceRule(true, game.LOBBY, "alive", true)
+	if name, ok := gs.VoicePreset(); ok {
+		t.Errorf("expected customized rules not to match a preset, got %q", name)
+	}
+}
+
+func TestVoicePresetsDontShareRules(t *testing.T) {
+	a, b := MakeGuildSettings(), MakeGuildSettings()
+	_ = a.ApplyVoicePreset("hardcore")
+	_ = b.ApplyVoicePreset("hardcore")
+	a.SetVoiceRule(true, game.LOBBY, "alive", true)
+	if name, _ := b.VoicePreset(); name != "hardcore" {
+		t.Error("changing one guild's preset rules changed another's")
+	}
+}
+
+func TestLookupVoicePresetSuggests(t *testing.T) {
+	_, err := LookupVoicePreset("hardcor")
+	if !errors.Is(err, ErrUnknownPreset) || !strings.Contains(err.Error(), `"hardcore"`) {
+		t.Errorf("expected a suggestion, got %v", err)
+	}
+}
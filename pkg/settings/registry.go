@@ -0,0 +1,533 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/das08/utils/pkg/discord"
+	"github.com/das08/utils/pkg/game"
+	"github.com/das08/utils/pkg/locale"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+var (
+	ErrUnknownSetting = errors.New("unknown setting")
+	ErrInvalidValue   = errors.New("invalid setting value")
+)
+
+type SettingType int
+
+const (
+	TypeBool SettingType = iota
+	TypeInt
+	TypeString
+	// TypeEnum is a string that must be one of the setting's Allowed values
+	TypeEnum
+	// TypeDuration is a time.Duration, written like game.ParseDelay reads it
+	TypeDuration
+	// TypeIDs is a list of Discord IDs, written separated by commas or spaces. Mentions are accepted too.
+	TypeIDs
+)
+
+func (t SettingType) String() string {
+	switch t {
+	case TypeBool:
+		return "bool"
+	case TypeInt:
+		return "int"
+	case TypeString:
+		return "string"
+	case TypeEnum:
+		return "enum"
+	case TypeDuration:
+		return "duration"
+	case TypeIDs:
+		return "ids"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	MinLeaderboardSize         = 1
+	MaxLeaderboardSize         = 10
+	MinLeaderboardMin          = 1
+	MaxLeaderboardMin          = 100
+	MinDeleteGameSummaryMinute = -1 // never delete
+	MaxDeleteGameSummaryMinute = 60
+	MinDelaySeconds            = 0
+	MaxDelaySeconds            = 10
+	MaxDelay                   = time.Second * MaxDelaySeconds
+	MaxStagger                 = time.Second
+)
+
+var DisplayRoomCodeValues = []string{"always", "spoiler", "never"}
+
+// Setting describes one user-facing setting, so command handlers and dashboards can be generated from the registry
+// instead of hardcoding names and parsing. Values are bool, int, string, time.Duration or []string, depending on Type.
+type Setting struct {
+	// Key matches the setting's field name in the stored JSON
+	Key         string
+	Type        SettingType
+	Description *i18n.Message
+	// Allowed lists the valid values of a TypeEnum setting
+	Allowed []string
+	// Min and Max bound a TypeInt setting, inclusive
+	Min, Max int
+	// MinDuration and MaxDuration bound a TypeDuration setting, inclusive
+	MinDuration, MaxDuration time.Duration
+	// Validate, if set, runs after the type and bounds checks
+	Validate func(v interface{}) error
+	// ExtractID reads an ID, or the mention of one, for TypeIDs settings and string settings that hold an ID
+	ExtractID func(mention string) (string, error)
+
+	Get func(gs *GuildSettings) interface{}
+	Set func(gs *GuildSettings, v interface{})
+}
+
+// Parse converts user input to the setting's type, and validates it
+func (s *Setting) Parse(input string) (interface{}, error) {
+	input = strings.TrimSpace(input)
+	var v interface{}
+	switch s.Type {
+	case TypeBool:
+		b, err := parseBool(input)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s expects true or false, got %q", ErrInvalidValue, s.Key, input)
+		}
+		v = b
+	case TypeInt:
+		n, err := strconv.Atoi(input)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s expects a number, got %q", ErrInvalidValue, s.Key, input)
+		}
+		v = n
+	case TypeDuration:
+		d, err := game.ParseDelay(input)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s expects a duration like 1.5s or 500ms, got %q", ErrInvalidValue, s.Key, input)
+		}
+		v = d.Duration()
+	case TypeEnum:
+		v = strings.ToLower(input)
+	case TypeIDs:
+		ids, err := s.parseIDs(input)
+		if err != nil {
+			return nil, err
+		}
+		v = ids
+	default:
+		if s.ExtractID != nil && input != "" {
+			id, err := s.ExtractID(input)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalidValue, s.Key, err)
+			}
+			input = id
+		}
+		v = input
+	}
+	if err := s.Check(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func parseBool(input string) (bool, error) {
+	switch strings.ToLower(input) {
+	case "on", "yes", "y":
+		return true, nil
+	case "off", "no", "n":
+		return false, nil
+	}
+	return strconv.ParseBool(input)
+}
+
+func (s *Setting) parseIDs(input string) ([]string, error) {
+	fields := strings.FieldsFunc(input, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+	ids := make([]string, len(fields))
+	for i, f := range fields {
+		id, err := s.ExtractID(f)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %q: %v", ErrInvalidValue, s.Key, f, err)
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+// Check validates an already-typed value
+func (s *Setting) Check(v interface{}) error {
+	switch s.Type {
+	case TypeBool:
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%w: %s expects a bool, got %T", ErrInvalidValue, s.Key, v)
+		}
+	case TypeInt:
+		n, ok := v.(int)
+		if !ok {
+			return fmt.Errorf("%w: %s expects an int, got %T", ErrInvalidValue, s.Key, v)
+		}
+		if n < s.Min || n > s.Max {
+			return fmt.Errorf("%w: %s must be between %d and %d, got %d", ErrInvalidValue, s.Key, s.Min, s.Max, n)
+		}
+	case TypeDuration:
+		d, ok := v.(time.Duration)
+		if !ok {
+			return fmt.Errorf("%w: %s expects a duration, got %T", ErrInvalidValue, s.Key, v)
+		}
+		if d < s.MinDuration || d > s.MaxDuration {
+			return fmt.Errorf("%w: %s must be between %s and %s, got %s", ErrInvalidValue, s.Key, s.MinDuration, s.MaxDuration, d)
+		}
+	case TypeIDs:
+		ids, ok := v.([]string)
+		if !ok {
+			return fmt.Errorf("%w: %s expects a list of IDs, got %T", ErrInvalidValue, s.Key, v)
+		}
+		for _, id := range ids {
+			if discord.ValidateSnowflake(id) != nil {
+				return fmt.Errorf("%w: %s expects Discord IDs, got %q", ErrInvalidValue, s.Key, id)
+			}
+		}
+	case TypeString, TypeEnum:
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%w: %s expects a string, got %T", ErrInvalidValue, s.Key, v)
+		}
+		if s.Type == TypeEnum && !contains(s.Allowed, str) {
+			return fmt.Errorf("%w: %s must be one of %s, got %q", ErrInvalidValue, s.Key, strings.Join(s.Allowed, ", "), str)
+		}
+	}
+	if s.Validate != nil {
+		return s.Validate(v)
+	}
+	return nil
+}
+
+// Apply parses and validates the input, then sets it. Nothing changes if the input is invalid.
+func (s *Setting) Apply(gs *GuildSettings, input string) error {
+	v, err := s.Parse(input)
+	if err != nil {
+		return err
+	}
+	s.Set(gs, v)
+	return nil
+}
+
+// Render formats the current value the same way Parse reads it
+func (s *Setting) Render(gs *GuildSettings) string {
+	return render(s.Get(gs))
+}
+
+func (s *Setting) Default() interface{} {
+	return s.Get(MakeGuildSettings())
+}
+
+func (s *Setting) LocalizeDescription(lang string) string {
+	return locale.LocalizeMessage(s.Description, lang)
+}
+
+func render(v interface{}) string {
+	switch v := v.(type) {
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case time.Duration:
+		return game.Delay(v).String()
+	case []string:
+		return strings.Join(v, ",")
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func contains(values []string, v string) bool {
+	for _, a := range values {
+		if a == v {
+			return true
+		}
+	}
+	return false
+}
+
+var registry = map[string]*Setting{}
+var registryKeys []string
+
+func register(s *Setting) {
+	if _, ok := registry[s.Key]; ok {
+		panic("settings: duplicate setting " + s.Key)
+	}
+	registry[s.Key] = s
+	registryKeys = append(registryKeys, s.Key)
+}
+
+// Lookup finds a setting by key, ignoring case
+func Lookup(key string) (*Setting, error) {
+	if s, ok := registry[key]; ok {
+		return s, nil
+	}
+	for k, s := range registry {
+		if strings.EqualFold(k, key) {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownSetting, key)
+}
+
+// Settings lists every registered setting, in registration order
+func Settings() []*Setting {
+	all := make([]*Setting, len(registryKeys))
+	for i, k := range registryKeys {
+		all[i] = registry[k]
+	}
+	return all
+}
+
+// Keys returns the registered keys, sorted
+func Keys() []string {
+	keys := append([]string(nil), registryKeys...)
+	sort.Strings(keys)
+	return keys
+}
+
+// Apply sets a guild's setting from user input, by key
+func Apply(gs *GuildSettings, key, input string) error {
+	s, err := Lookup(key)
+	if err != nil {
+		return err
+	}
+	return s.Apply(gs, input)
+}
+
+func validateLanguage(v interface{}) error {
+	lang := v.(string)
+	// make sure the bundle's been loaded, or only the default language is known
+	locale.GetBundle()
+	if _, ok := locale.GetLanguages()[lang]; !ok {
+		return fmt.Errorf("%w: %q is not a loaded language", ErrInvalidValue, lang)
+	}
+	return nil
+}
+
+func validateChannelID(v interface{}) error {
+	if id := v.(string); id != "" && discord.ValidateSnowflake(id) != nil {
+		return fmt.Errorf("%w: %q is not a channel ID", ErrInvalidValue, id)
+	}
+	return nil
+}
+
+// settingPhases are the phases that have voice rules and delays
+var settingPhases = []game.Phase{game.LOBBY, game.TASKS, game.DISCUSS}
+
+func phaseKey(phase game.Phase) string {
+	return strings.ToLower(string(phase.ToString()))
+}
+
+// delayKey names the setting for one phase transition, like delays.lobby.tasks
+func delayKey(origin, dest game.Phase) string {
+	return "delays." + phaseKey(origin) + "." + phaseKey(dest)
+}
+
+// voiceRuleKey names the setting for one voice rule, like voiceRules.mute.tasks.alive
+func voiceRuleKey(isMute bool, phase game.Phase, state string) string {
+	action := "deaf"
+	if isMute {
+		action = "mute"
+	}
+	return "voiceRules." + action + "." + phaseKey(phase) + "." + state
+}
+
+// descriptionID is where a setting's description is in the locale files
+func descriptionID(key string) string {
+	return "settings." + key + ".Description"
+}
+
+var phaseDescriptions = map[game.Phase]string{
+	game.LOBBY:   "in the lobby",
+	game.TASKS:   "during tasks",
+	game.DISCUSS: "during discussion",
+}
+
+func init() {
+	register(&Setting{
+		Key:         "adminIDs",
+		Type:        TypeIDs,
+		Description: &i18n.Message{ID: "settings.adminIDs.Description", Other: "The users who can change the bot's settings"},
+		ExtractID:   discord.ExtractUserIDFromText,
+		Get:         func(gs *GuildSettings) interface{} { return gs.GetAdminUserIDs() },
+		Set:         func(gs *GuildSettings, v interface{}) { gs.SetAdminUserIDs(v.([]string)) },
+	})
+	register(&Setting{
+		Key:         "permissionRoleIDs",
+		Type:        TypeIDs,
+		Description: &i18n.Message{ID: "settings.permissionRoleIDs.Description", Other: "The roles that can run games"},
+		ExtractID:   discord.ExtractRoleIDFromText,
+		Get:         func(gs *GuildSettings) interface{} { return gs.GetPermissionRoleIDs() },
+		Set:         func(gs *GuildSettings, v interface{}) { gs.SetPermissionRoleIDs(v.([]string)) },
+	})
+	register(&Setting{
+		Key:         "language",
+		Type:        TypeString,
+		Description: &i18n.Message{ID: "settings.language.Description", Other: "The language the bot responds in"},
+		Validate:    validateLanguage,
+		Get:         func(gs *GuildSettings) interface{} { return gs.GetLanguage() },
+		Set:         func(gs *GuildSettings, v interface{}) { gs.SetLanguage(v.(string)) },
+	})
+	register(&Setting{
+		Key:         "mapVersion",
+		Type:        TypeEnum,
+		Description: &i18n.Message{ID: "settings.mapVersion.Description", Other: "Whether to show the simple or detailed map"},
+		Allowed:     []string{"simple", "detailed"},
+		Get: func(gs *GuildSettings) interface{} {
+			if gs.GetMapDetailed() {
+				return "detailed"
+			}
+			return "simple"
+		},
+		Set: func(gs *GuildSettings, v interface{}) { gs.SetMapDetailed(v.(string) == "detailed") },
+	})
+	register(&Setting{
+		Key:         "deleteGameSummary",
+		Type:        TypeInt,
+		Description: &i18n.Message{ID: "settings.deleteGameSummary.Description", Other: "Minutes to keep the match summary before deleting it"},
+		Min:         MinDeleteGameSummaryMinute,
+		Max:         MaxDeleteGameSummaryMinute,
+		Get:         func(gs *GuildSettings) interface{} { return gs.GetDeleteGameSummaryMinutes() },
+		Set:         func(gs *GuildSettings, v interface{}) { gs.SetDeleteGameSummaryMinutes(v.(int)) },
+	})
+	register(&Setting{
+		Key:         "unmuteDeadDuringTasks",
+		Type:        TypeBool,
+		Description: &i18n.Message{ID: "settings.unmuteDeadDuringTasks.Description", Other: "Unmute dead players during tasks"},
+		Get:         func(gs *GuildSettings) interface{} { return gs.GetUnmuteDeadDuringTasks() },
+		Set:         func(gs *GuildSettings, v interface{}) { gs.SetUnmuteDeadDuringTasks(v.(bool)) },
+	})
+	register(&Setting{
+		Key:         "autoRefresh",
+		Type:        TypeBool,
+		Description: &i18n.Message{ID: "settings.autoRefresh.Description", Other: "Resend the status message when it's pushed too far up the channel"},
+		Get:         func(gs *GuildSettings) interface{} { return gs.GetAutoRefresh() },
+		Set:         func(gs *GuildSettings, v interface{}) { gs.SetAutoRefresh(v.(bool)) },
+	})
+	register(&Setting{
+		Key:         "matchSummaryChannelID",
+		Type:        TypeString,
+		Description: &i18n.Message{ID: "settings.matchSummaryChannelID.Description", Other: "The channel match summaries are posted in"},
+		Validate:    validateChannelID,
+		ExtractID:   discord.ExtractChannelIDFromText,
+		Get:         func(gs *GuildSettings) interface{} { return gs.GetMatchSummaryChannelID() },
+		Set:         func(gs *GuildSettings, v interface{}) { gs.SetMatchSummaryChannelID(v.(string)) },
+	})
+	register(&Setting{
+		Key:         "leaderboardMention",
+		Type:        TypeBool,
+		Description: &i18n.Message{ID: "settings.leaderboardMention.Description", Other: "Mention players on the leaderboard"},
+		Get:         func(gs *GuildSettings) interface{} { return gs.GetLeaderboardMention() },
+		Set:         func(gs *GuildSettings, v interface{}) { gs.SetLeaderboardMention(v.(bool)) },
+	})
+	register(&Setting{
+		Key:         "leaderboardSize",
+		Type:        TypeInt,
+		Description: &i18n.Message{ID: "settings.leaderboardSize.Description", Other: "How many players the leaderboard shows"},
+		Min:         MinLeaderboardSize,
+		Max:         MaxLeaderboardSize,
+		Get:         func(gs *GuildSettings) interface{} { return gs.GetLeaderboardSize() },
+		Set:         func(gs *GuildSettings, v interface{}) { gs.SetLeaderboardSize(v.(int)) },
+	})
+	register(&Setting{
+		Key:         "leaderboardMin",
+		Type:        TypeInt,
+		Description: &i18n.Message{ID: "settings.leaderboardMin.Description", Other: "How many games a player needs to appear on the leaderboard"},
+		Min:         MinLeaderboardMin,
+		Max:         MaxLeaderboardMin,
+		Get:         func(gs *GuildSettings) interface{} { return gs.GetLeaderboardMin() },
+		Set:         func(gs *GuildSettings, v interface{}) { gs.SetLeaderboardMin(v.(int)) },
+	})
+	register(&Setting{
+		Key:         "muteSpectator",
+		Type:        TypeBool,
+		Description: &i18n.Message{ID: "settings.muteSpectator.Description", Other: "Mute spectators like dead players"},
+		Get:         func(gs *GuildSettings) interface{} { return gs.GetMuteSpectator() },
+		Set:         func(gs *GuildSettings, v interface{}) { gs.SetMuteSpectator(v.(bool)) },
+	})
+	register(&Setting{
+		Key:         "displayRoomCode",
+		Type:        TypeEnum,
+		Description: &i18n.Message{ID: "settings.displayRoomCode.Description", Other: "Whether the room code is shown, hidden behind a spoiler, or never shown"},
+		Allowed:     DisplayRoomCodeValues,
+		Get:         func(gs *GuildSettings) interface{} { return gs.GetDisplayRoomCode() },
+		Set:         func(gs *GuildSettings, v interface{}) { gs.SetDisplayRoomCode(v.(string)) },
+	})
+
+	for _, isMute := range []bool{true, false} {
+		action := "deafen"
+		if isMute {
+			action = "mute"
+		}
+		for _, phase := range settingPhases {
+			for _, state := range game.PlayerStates {
+				isMute, phase, state := isMute, phase, string(state)
+				key := voiceRuleKey(isMute, phase, state)
+				register(&Setting{
+					Key:  key,
+					Type: TypeBool,
+					Description: &i18n.Message{
+						ID:    descriptionID(key),
+						Other: fmt.Sprintf("Whether to %s %s players %s", action, state, phaseDescriptions[phase]),
+					},
+					Get: func(gs *GuildSettings) interface{} { return gs.GetVoiceRule(isMute, phase, state) },
+					Set: func(gs *GuildSettings, v interface{}) { gs.SetVoiceRule(isMute, phase, state, v.(bool)) },
+				})
+			}
+		}
+	}
+
+	for _, origin := range settingPhases {
+		for _, dest := range settingPhases {
+			origin, dest := origin, dest
+			key := delayKey(origin, dest)
+			register(&Setting{
+				Key:  key,
+				Type: TypeDuration,
+				Description: &i18n.Message{
+					ID:    descriptionID(key),
+					Other: fmt.Sprintf("How long to wait before changing voice states when the game goes from %s to %s", phaseKey(origin), phaseKey(dest)),
+				},
+				MinDuration: MinDelaySeconds * time.Second,
+				MaxDuration: MaxDelay,
+				Get:         func(gs *GuildSettings) interface{} { return gs.GetDuration(origin, dest) },
+				Set:         func(gs *GuildSettings, v interface{}) { gs.SetDuration(origin, dest, v.(time.Duration)) },
+			})
+		}
+	}
+	register(&Setting{
+		Key:         "delays.stagger",
+		Type:        TypeDuration,
+		Description: &i18n.Message{ID: "settings.delays.stagger.Description", Other: "How far apart to space each player's voice change, so big lobbies don't hit Discord's rate limits"},
+		MaxDuration: MaxStagger,
+		Get: func(gs *GuildSettings) interface{} {
+			stagger, _ := gs.GetDelayStagger()
+			return stagger
+		},
+		Set: func(gs *GuildSettings, v interface{}) { gs.SetStagger(v.(time.Duration)) },
+	})
+	register(&Setting{
+		Key:         "delays.jitter",
+		Type:        TypeDuration,
+		Description: &i18n.Message{ID: "settings.delays.jitter.Description", Other: "Up to how long to randomly delay each player's voice change by"},
+		MaxDuration: MaxStagger,
+		Get: func(gs *GuildSettings) interface{} {
+			_, jitter := gs.GetDelayStagger()
+			return jitter
+		},
+		Set: func(gs *GuildSettings, v interface{}) { gs.SetJitter(v.(time.Duration)) },
+	})
+}
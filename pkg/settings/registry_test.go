@@ -0,0 +1,134 @@
+package settings
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/das08/utils/pkg/game"
+)
+
+func TestRegistryApply(t *testing.T) {
+	gs := MakeGuildSettings()
+
+	tests := []struct {
+		key, input, rendered string
+		err                  error
+	}{
+		{"leaderboardSize", "5", "5", nil},
+		{"leaderboardSize", "0", "5", ErrInvalidValue},
+		{"leaderboardSize", "lots", "5", ErrInvalidValue},
+		{"deleteGameSummary", "-1", "-1", nil},
+		{"deleteGameSummary", "61", "-1", ErrInvalidValue},
+		{"displayRoomCode", "Spoiler", "spoiler", nil},
+		{"displayRoomCode", "sometimes", "spoiler", ErrInvalidValue},
+		{"autorefresh", "on", "true", nil},
+		{"mapVersion", "detailed", "detailed", nil},
+		{"language", "xx", "en", ErrInvalidValue},
+		{"delays.lobby.tasks", "3", "3", nil},
+		{"delays.lobby.tasks", "11", "3", ErrInvalidValue},
+		{"delays.discussion.tasks", "750ms", "750ms", nil},
+		{"delays.discussion.tasks", "later", "750ms", ErrInvalidValue},
+		{"delays.stagger", "0.1", "100ms", nil},
+		{"delays.jitter", "2s", "0", ErrInvalidValue},
+		{"adminIDs", "<@!141101495071408128>, 141101495071408129", "141101495071408128,141101495071408129", nil},
+		{"adminIDs", "everyone", "141101495071408128,141101495071408129", ErrInvalidValue},
+		{"adminIDs", "<@&141101495071408130>", "141101495071408128,141101495071408129", ErrInvalidValue},
+		{"adminIDs", "0", "141101495071408128,141101495071408129", ErrInvalidValue},
+		{"permissionRoleIDs", "<@&141101495071408130>", "141101495071408130", nil},
+		{"voiceRules.mute.tasks.dead", "yes", "true", nil},
+		{"voiceRules.deaf.lobby.spectator", "maybe", "false", ErrInvalidValue},
+		{"matchSummaryChannelID", "#general", "", ErrInvalidValue},
+		{"matchSummaryChannelID", "<#141101495071408131>", "141101495071408131", nil},
+		{"nope", "1", "", ErrUnknownSetting},
+	}
+	for _, test := range tests {
+		err := Apply(gs, test.key, test.input)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s=%s: expected error %v, got %v", test.key, test.input, test.err, err)
+		}
+		if s, err := Lookup(test.key); err == nil {
+			if r := s.Render(gs); r != test.rendered {
+				t.Errorf("%s=%s: expected %q, got %q", test.key, test.input, test.rendered, r)
+			}
+		}
+	}
+
+	if gs.GetDelay(game.LOBBY, game.TASKS) != 3 {
+		t.Error("expected the delay setting to change the guild's delays")
+	}
+	if !gs.GetVoiceRule(true, game.TASKS, "dead") {
+		t.Error("expected the voice rule setting to change the guild's voice rules")
+	}
+}
+
+func TestSettersDontValidate(t *testing.T) {
+	gs := MakeGuildSettings()
+	gs.SetLeaderboardSize(MaxLeaderboardSize + 10)
+	gs.SetDelay(game.LOBBY, game.TASKS, MaxDelaySeconds+5)
+	if gs.GetLeaderboardSize() != MaxLeaderboardSize+10 || gs.GetDelay(game.LOBBY, game.TASKS) != MaxDelaySeconds+5 {
+		t.Error("expected the setters to store values without validating them")
+	}
+
+	if err := Apply(gs, "leaderboardSize", strconv.Itoa(MaxLeaderboardSize+1)); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("expected Apply to validate, got %v", err)
+	}
+}
+
+func TestConcurrentStaggerAndJitter(t *testing.T) {
+	gs := MakeGuildSettings()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_ = Apply(gs, "delays.stagger", "100ms")
+	}()
+	go func() {
+		defer wg.Done()
+		_ = Apply(gs, "delays.jitter", "50ms")
+	}()
+	wg.Wait()
+	if stagger, jitter := gs.GetDelayStagger(); stagger != time.Millisecond*100 || jitter != time.Millisecond*50 {
+		t.Errorf("expected both updates to apply, got stagger %s and jitter %s", stagger, jitter)
+	}
+}
+
+func TestDescriptionsAreTranslated(t *testing.T) {
+	var messages map[string]string
+	if _, err := toml.DecodeFile("../../locales/active.en.toml", &messages); err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]string{}
+	for _, s := range Settings() {
+		if other, ok := ids[s.Description.ID]; ok {
+			t.Errorf("%s shares its description ID with %s", s.Key, other)
+		}
+		ids[s.Description.ID] = s.Key
+		if messages[s.Description.ID] != s.Description.Other {
+			t.Errorf("expected %s in active.en.toml to be %q, got %q", s.Description.ID, s.Description.Other, messages[s.Description.ID])
+		}
+	}
+	for _, p := range VoicePresets {
+		if messages[p.Description.ID] != p.Description.Other {
+			t.Errorf("expected %s in active.en.toml to be %q, got %q", p.Description.ID, p.Description.Other, messages[p.Description.ID])
+		}
+	}
+}
+
+func TestRegistryDefaults(t *testing.T) {
+	gs := MakeGuildSettings()
+	for _, s := range Settings() {
+		if err := s.Check(s.Default()); err != nil {
+			t.Errorf("the default for %s is invalid: %v", s.Key, err)
+		}
+		if s.Render(gs) != render(s.Default()) {
+			t.Errorf("%s doesn't render its default", s.Key)
+		}
+		if s.Description == nil || s.Description.ID == "" {
+			t.Errorf("%s has no description", s.Key)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package settings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/das08/utils/pkg/game"
+	"github.com/das08/utils/pkg/locale"
+	"github.com/das08/utils/pkg/rediskey"
+	"github.com/go-redis/redis/v8"
+)
+
+const DefaultUpdateRetries = 5
+
+var ErrUpdateConflict = errors.New("guild settings kept changing during the update")
+
+// Store persists GuildSettings in Redis, keyed by the hashed guild ID
+type Store struct {
+	client *redis.Client
+	// MaxRetries is how many times Update retries when the settings change underneath it
+	MaxRetries int
+}
+
+func NewStore(client *redis.Client) *Store {
+	return &Store{
+		client:     client,
+		MaxRetries: DefaultUpdateRetries,
+	}
+}
+
+func settingsKey(guildID string) string {
+	return rediskey.GuildSettings(rediskey.HashGuildID(guildID))
+}
+
+// Get returns the settings for a guild, or the defaults if none have been saved
+func (s *Store) Get(ctx context.Context, guildID string) (*GuildSettings, error) {
+	data, err := s.client.Get(ctx, settingsKey(guildID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return MakeGuildSettings(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Unmarshal(data)
+}
+
+func (s *Store) Save(ctx context.Context, guildID string, gs *GuildSettings) error {
+	data, err := json.Marshal(gs)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, settingsKey(guildID), data, 0).Err()
+}
+
+func (s *Store) Delete(ctx context.Context, guildID string) error {
+	return s.client.Del(ctx, settingsKey(guildID)).Err()
+}
+
+// Update loads a guild's settings, applies fn, and saves the result, retrying from scratch if another writer changed
+// the settings in the meantime. If fn returns an error, nothing is saved.
+func (s *Store) Update(ctx context.Context, guildID string, fn func(*GuildSettings) error) (*GuildSettings, error) {
+	key := settingsKey(guildID)
+	var gs *GuildSettings
+
+	txf := func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		switch {
+		case errors.Is(err, redis.Nil):
+			gs = MakeGuildSettings()
+		case err != nil:
+			return err
+		default:
+			gs, err = Unmarshal(data)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := fn(gs); err != nil {
+			return err
+		}
+		updated, err := json.Marshal(gs)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, updated, 0)
+			return nil
+		})
+		return err
+	}
+
+	for i := 0; i <= s.MaxRetries; i++ {
+		err := s.client.Watch(ctx, txf, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return gs, nil
+	}
+	return nil, ErrUpdateConflict
+}
+
+// decodeGuildSettings reads stored settings over the top of the defaults, so fields added since they were saved get
+// their default values instead of zero values
+func decodeGuildSettings(data []byte) (*GuildSettings, error) {
+	gs := MakeGuildSettings()
+	if err := json.Unmarshal(data, gs); err != nil {
+		return nil, err
+	}
+	fillDefaults(gs)
+	return gs, nil
+}
+
+// fillDefaults replaces values that were explicitly stored as empty (or null) and that the bot can't work with
+func fillDefaults(gs *GuildSettings) {
+	if gs.Language == "" {
+		gs.Language = locale.DefaultLang
+	}
+	if gs.AdminUserIDs == nil {
+		gs.AdminUserIDs = []string{}
+	}
+	if gs.PermissionRoleIDs == nil {
+		gs.PermissionRoleIDs = []string{}
+	}
+	if gs.Delays.Delays == nil {
+		gs.Delays = game.MakeDefaultDelays()
+	}
+	if gs.VoiceRules.MuteRules == nil || gs.VoiceRules.DeafRules == nil {
+		gs.VoiceRules = game.MakeMuteAndDeafenRules()
+	}
+	if gs.MapVersion == "" {
+		gs.MapVersion = "simple"
+	}
+	if gs.DisplayRoomCode == "" {
+		gs.DisplayRoomCode = "always"
+	}
+}
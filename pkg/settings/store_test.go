@@ -0,0 +1,123 @@
+package settings
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/das08/utils/pkg/rediskey"
+	"github.com/go-redis/redis/v8"
+)
+
+func newTestStore(t *testing.T) (*miniredis.Miniredis, *Store) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return mr, NewStore(client)
+}
+
+func TestStoreGetSaveDelete(t *testing.T) {
+	ctx := context.Background()
+	_, store := newTestStore(t)
+
+	gs, err := store.Get(ctx, "141101495071408128")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gs.GetLeaderboardSize() != DefaultLeaderboardSize || gs.GetLanguage() != "en" {
+		t.Error("a guild without saved settings should get the defaults")
+	}
+
+	gs.SetLeaderboardSize(5)
+	gs.SetMuteSpectator(true)
+	if err := store.Save(ctx, "141101495071408128", gs); err != nil {
+		t.Fatal(err)
+	}
+	gs, err = store.Get(ctx, "141101495071408128")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gs.GetLeaderboardSize() != 5 || !gs.GetMuteSpectator() {
+		t.Error("saved settings were not loaded back")
+	}
+
+	if err := store.Delete(ctx, "141101495071408128"); err != nil {
+		t.Fatal(err)
+	}
+	gs, err = store.Get(ctx, "141101495071408128")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gs.GetLeaderboardSize() != DefaultLeaderboardSize {
+		t.Error("deleted settings should fall back to the defaults")
+	}
+}
+
+func TestStoreFillsMissingFields(t *testing.T) {
+	ctx := context.Background()
+	mr, store := newTestStore(t)
+
+	// saved before leaderboardMin and displayRoomCode existed
+	err := mr.Set(rediskey.GuildSettings(rediskey.HashGuildID("141101495071408128")), `{"language":"","adminIDs":null,"leaderboardSize":5}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs, err := store.Get(ctx, "141101495071408128")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gs.GetLeaderboardSize() != 5 {
+		t.Errorf("expected the stored leaderboard size, got %d", gs.GetLeaderboardSize())
+	}
+	if gs.LeaderboardMin != DefaultLeaderboardMin || gs.DisplayRoomCode != "always" || gs.Language != "en" || gs.AdminUserIDs == nil {
+		t.Errorf("missing fields should have been filled with defaults: %+v", gs)
+	}
+	if gs.GetDelay(0, 1) != 7 {
+		t.Error("missing delays should have been filled with defaults")
+	}
+}
+
+func TestStoreUpdate(t *testing.T) {
+	ctx := context.Background()
+	_, store := newTestStore(t)
+	store.MaxRetries = 100
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.Update(ctx, "141101495071408128", func(gs *GuildSettings) error {
+				gs.SetLeaderboardMin(gs.GetLeaderboardMin() + 1)
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	gs, err := store.Get(ctx, "141101495071408128")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gs.GetLeaderboardMin() != DefaultLeaderboardMin+10 {
+		t.Errorf("expected every concurrent update to apply, got %d", gs.GetLeaderboardMin())
+	}
+
+	failed := errors.New("no thanks")
+	_, err = store.Update(ctx, "141101495071408128", func(gs *GuildSettings) error {
+		gs.SetLeaderboardMin(1)
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Errorf("expected the update function's error, got %v", err)
+	}
+	gs, _ = store.Get(ctx, "141101495071408128")
+	if gs.GetLeaderboardMin() != DefaultLeaderboardMin+10 {
+		t.Error("a failed update should not be saved")
+	}
+}
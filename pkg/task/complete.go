@@ -0,0 +1,124 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/das08/utils/pkg/rediskey"
+	"github.com/go-redis/redis/v8"
+)
+
+// DefaultTaskTimeout is how long SubmitModifyTask waits for a capture client if the context has no deadline
+const DefaultTaskTimeout = time.Second * 5
+
+const TaskListTTLSeconds = 60
+
+// WithdrawTimeout bounds how long a timed out SubmitModifyTask spends taking its task back off the list
+const WithdrawTimeout = time.Second
+
+var ErrTaskTimeout = errors.New("timed out waiting for the task to be completed")
+
+// ModifyResult is what a capture client reports back after attempting a ModifyTask
+type ModifyResult struct {
+	TaskID  string `json:"taskID"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// SubmitModifyTask hands a task to the capture client for connectCode and waits for it to report back. If nothing
+// answers before the context is done (or DefaultTaskTimeout passes), the task is withdrawn so it can't be applied late,
+// and ErrTaskTimeout is returned.
+func SubmitModifyTask(ctx context.Context, redis *redis.Client, connectCode string, task ModifyTask) (ModifyResult, error) {
+	result := ModifyResult{TaskID: task.TaskID}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultTaskTimeout)
+		defer cancel()
+	}
+
+	jBytes, err := json.Marshal(task)
+	if err != nil {
+		return result, err
+	}
+
+	// subscribe before pushing, so a fast worker can't complete the task before we're listening
+	sub := redis.Subscribe(ctx, rediskey.CompleteTask(task.TaskID))
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		return result, err
+	}
+
+	list := rediskey.TasksList(connectCode)
+	if err := redis.RPush(ctx, list, string(jBytes)).Err(); err != nil {
+		return result, err
+	}
+	redis.Expire(ctx, list, TaskListTTLSeconds*time.Second)
+
+	select {
+	case msg, ok := <-sub.Channel():
+		if !ok {
+			return result, errors.New("subscription closed before the task was completed")
+		}
+		return parseModifyResult(task.TaskID, msg.Payload)
+	case <-ctx.Done():
+		// use a fresh context, since this one is already done
+		withdrawCtx, cancel := context.WithTimeout(context.Background(), WithdrawTimeout)
+		defer cancel()
+		removed, err := redis.LRem(withdrawCtx, list, 1, string(jBytes)).Result()
+		if err != nil {
+			return result, fmt.Errorf("%w: %v, and the task could not be withdrawn so it may still be applied: %v", ErrTaskTimeout, ctx.Err(), err)
+		}
+		if removed == 0 {
+			return result, fmt.Errorf("%w: %v, and a worker already took the task so it may still be applied", ErrTaskTimeout, ctx.Err())
+		}
+		return result, fmt.Errorf("%w: %v", ErrTaskTimeout, ctx.Err())
+	}
+}
+
+// parseModifyResult also accepts the bare "true"/"false" that older capture clients publish
+func parseModifyResult(taskID, payload string) (ModifyResult, error) {
+	if success, err := strconv.ParseBool(payload); err == nil {
+		return ModifyResult{TaskID: taskID, Success: success}, nil
+	}
+
+	result := ModifyResult{}
+	if err := json.Unmarshal([]byte(payload), &result); err != nil {
+		return ModifyResult{TaskID: taskID}, fmt.Errorf("invalid completion for task %s: %w", taskID, err)
+	}
+	result.TaskID = taskID
+	return result, nil
+}
+
+// PopModifyTask is the worker side of SubmitModifyTask, waiting up to timeout for a task for connectCode
+func PopModifyTask(ctx context.Context, redis *redis.Client, connectCode string, timeout time.Duration) (ModifyTask, error) {
+	task := ModifyTask{}
+	elems, err := redis.BLPop(ctx, timeout, rediskey.TasksList(connectCode)).Result()
+	if err != nil {
+		return task, err
+	}
+	if len(elems) < 2 {
+		return task, errors.New("insufficient elements returned")
+	}
+	err = json.Unmarshal([]byte(elems[1]), &task)
+	return task, err
+}
+
+// CompleteModifyTask reports the outcome of a task to whoever submitted it. A nil taskErr means it was applied.
+func CompleteModifyTask(ctx context.Context, redis *redis.Client, taskID string, taskErr error) error {
+	result := ModifyResult{
+		TaskID:  taskID,
+		Success: taskErr == nil,
+	}
+	if taskErr != nil {
+		result.Error = taskErr.Error()
+	}
+	jBytes, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return redis.Publish(ctx, rediskey.CompleteTask(taskID), string(jBytes)).Err()
+}
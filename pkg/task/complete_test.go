@@ -0,0 +1,84 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/das08/utils/pkg/rediskey"
+)
+
+func TestSubmitModifyTask(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+
+	task := NewModifyTask(1234, 5678, PatchParams{Mute: true})
+	go func() {
+		popped, err := PopModifyTask(ctx, client, "ABCDEF", time.Second)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_ = CompleteModifyTask(ctx, client, popped.TaskID, errors.New("missing permissions"))
+	}()
+
+	result, err := SubmitModifyTask(ctx, client, "ABCDEF", task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TaskID != task.TaskID || result.Success || result.Error != "missing permissions" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
+func TestSubmitModifyTaskTimeout(t *testing.T) {
+	mr, client := newTestRedis(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	_, err := SubmitModifyTask(ctx, client, "ABCDEF", NewModifyTask(1234, 5678, PatchParams{Mute: true}))
+	if !errors.Is(err, ErrTaskTimeout) {
+		t.Errorf("expected ErrTaskTimeout, got %v", err)
+	}
+	if mr.Exists(rediskey.TasksList("ABCDEF")) {
+		t.Error("a timed out task should be withdrawn from the list")
+	}
+}
+
+func TestSubmitModifyTaskTimeoutAfterPop(t *testing.T) {
+	_, client := newTestRedis(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+
+	// a worker takes the task, but never reports back
+	popped := make(chan struct{})
+	go func() {
+		defer close(popped)
+		if _, err := PopModifyTask(context.Background(), client, "ABCDEF", time.Second); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	_, err := SubmitModifyTask(ctx, client, "ABCDEF", NewModifyTask(1234, 5678, PatchParams{Mute: true}))
+	<-popped
+	if !errors.Is(err, ErrTaskTimeout) || !strings.Contains(err.Error(), "may still be applied") {
+		t.Errorf("expected a timeout warning the task may still be applied, got %v", err)
+	}
+}
+
+func TestParseModifyResult(t *testing.T) {
+	result, err := parseModifyResult("abc", `{"taskID":"other","success":true}`)
+	if err != nil || !result.Success || result.TaskID != "abc" {
+		t.Errorf("expected a success for task abc, got %+v, %v", result, err)
+	}
+	// older capture clients publish a bare boolean
+	result, err = parseModifyResult("abc", "false")
+	if err != nil || result.Success || result.TaskID != "abc" {
+		t.Errorf("expected a failure for task abc, got %+v, %v", result, err)
+	}
+	if _, err := parseModifyResult("abc", "not json"); err == nil {
+		t.Error("expected an error for an invalid completion")
+	}
+}
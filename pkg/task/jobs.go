@@ -2,10 +2,10 @@ package task
 
 import (
 	"context"
-	"encoding/json"
-	"time"
+	"crypto/rand"
+	"encoding/hex"
 
-	"github.com/das08/utils/pkg/rediskey"
+	"github.com/das08/utils/pkg/queue"
 	"github.com/go-redis/redis/v8"
 )
 
@@ -22,57 +22,41 @@ const (
 type Job struct {
 	JobType JobType     `json:"type"`
 	Payload interface{} `json:"payload"`
+	ID      string      `json:"id,omitempty"`
+
+	// lease is only set on jobs returned by ClaimJob, so AckJob can find them again
+	lease queue.Lease
 }
 
 const JobTTLSeconds = 3600
 
 func PushJob(ctx context.Context, redis *redis.Client, connCode string, jobType JobType, payload string) error {
-	job := Job{
-		JobType: jobType,
-		Payload: payload,
-	}
-	jBytes, err := json.Marshal(job)
-	if err != nil {
-		return err
-	}
-
-	count, err := redis.RPush(ctx, rediskey.JobNamespace+connCode, string(jBytes)).Result()
-	if err == nil {
-		notify(ctx, redis, connCode)
-	}
-
-	// new list
-	if count < 2 {
-		// log.Printf("Set TTL for List")
-		redis.Expire(ctx, rediskey.JobNamespace+connCode, JobTTLSeconds*time.Second)
-	}
+	return NewJobQueue(queue.NewRedis(redis)).Push(ctx, connCode, jobType, payload)
+}
 
-	return err
+// newJobID makes otherwise identical jobs distinguishable while they sit in a processing list
+func newJobID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
 }
 
 func notify(ctx context.Context, redis *redis.Client, connCode string) {
-	redis.Publish(ctx, rediskey.JobNamespace+connCode+":notify", true)
+	NewJobQueue(queue.NewRedis(redis)).notify(ctx, connCode)
 }
 
 func Subscribe(ctx context.Context, redis *redis.Client, connCode string) *redis.PubSub {
-	return redis.Subscribe(ctx, rediskey.JobNamespace+connCode+":notify")
+	return redis.Subscribe(ctx, notifyChannel(connCode))
 }
 
 func PopJob(ctx context.Context, redis *redis.Client, connCode string) (Job, error) {
-	str, err := redis.LPop(ctx, rediskey.JobNamespace+connCode).Result()
-
-	j := Job{}
-	if err != nil {
-		return j, err
-	}
-	err = json.Unmarshal([]byte(str), &j)
-	return j, err
+	return NewJobQueue(queue.NewRedis(redis)).Pop(ctx, connCode)
 }
 
 func Ack(ctx context.Context, redis *redis.Client, connCode string) {
-	redis.Publish(ctx, rediskey.JobNamespace+connCode+":ack", true)
+	_ = NewJobQueue(queue.NewRedis(redis)).Ack(ctx, connCode)
 }
 
 func AckSubscribe(ctx context.Context, redis *redis.Client, connCode string) *redis.PubSub {
-	return redis.Subscribe(ctx, rediskey.JobNamespace+connCode+":ack")
+	return redis.Subscribe(ctx, ackChannel(connCode))
 }
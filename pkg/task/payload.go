@@ -0,0 +1,97 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/das08/utils/pkg/game"
+	"github.com/go-redis/redis/v8"
+)
+
+var ErrJobTypeMismatch = errors.New("job type does not match the requested payload")
+
+func (t JobType) String() string {
+	return game.PayloadKind(int(t))
+}
+
+func PushConnectionJob(ctx context.Context, redis *redis.Client, connCode string, connected bool) error {
+	return PushJob(ctx, redis, connCode, ConnectionJob, strconv.FormatBool(connected))
+}
+
+func PushLobbyJob(ctx context.Context, redis *redis.Client, connCode string, lobby game.Lobby) error {
+	return pushJSONJob(ctx, redis, connCode, LobbyJob, lobby)
+}
+
+func PushStateJob(ctx context.Context, redis *redis.Client, connCode string, phase game.Phase) error {
+	return PushJob(ctx, redis, connCode, StateJob, strconv.Itoa(int(phase)))
+}
+
+func PushPlayerJob(ctx context.Context, redis *redis.Client, connCode string, player game.Player) error {
+	return pushJSONJob(ctx, redis, connCode, PlayerJob, player)
+}
+
+func PushGameOverJob(ctx context.Context, redis *redis.Client, connCode string, gameover game.Gameover) error {
+	return pushJSONJob(ctx, redis, connCode, GameOverJob, gameover)
+}
+
+func pushJSONJob(ctx context.Context, redis *redis.Client, connCode string, jobType JobType, v interface{}) error {
+	payload, err := game.MarshalPayload(v)
+	if err != nil {
+		return err
+	}
+	return PushJob(ctx, redis, connCode, jobType, payload)
+}
+
+// payload returns the raw payload after checking the job is of the expected type
+func (j *Job) payload(expected JobType) ([]byte, error) {
+	if j.JobType != expected {
+		return nil, fmt.Errorf("%w: wanted a %s job, but got a %s job", ErrJobTypeMismatch, expected, j.JobType)
+	}
+	str, ok := j.Payload.(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s job payload is a %T, not a string", game.ErrPayloadShape, j.JobType, j.Payload)
+	}
+	return []byte(str), nil
+}
+
+func (j *Job) DecodeConnection() (bool, error) {
+	data, err := j.payload(ConnectionJob)
+	if err != nil {
+		return false, err
+	}
+	return game.UnmarshalConnection(data)
+}
+
+func (j *Job) DecodeLobby() (game.Lobby, error) {
+	data, err := j.payload(LobbyJob)
+	if err != nil {
+		return game.Lobby{}, err
+	}
+	return game.UnmarshalLobby(data)
+}
+
+func (j *Job) DecodePhase() (game.Phase, error) {
+	data, err := j.payload(StateJob)
+	if err != nil {
+		return game.UNINITIALIZED, err
+	}
+	return game.UnmarshalPhase(data)
+}
+
+func (j *Job) DecodePlayer() (game.Player, error) {
+	data, err := j.payload(PlayerJob)
+	if err != nil {
+		return game.Player{}, err
+	}
+	return game.UnmarshalPlayer(data)
+}
+
+func (j *Job) DecodeGameOver() (game.Gameover, error) {
+	data, err := j.payload(GameOverJob)
+	if err != nil {
+		return game.Gameover{}, err
+	}
+	return game.UnmarshalGameover(data)
+}
@@ -0,0 +1,67 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/das08/utils/pkg/game"
+)
+
+func TestTypedJobRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+
+	lobby := game.Lobby{LobbyCode: "ABCDEF", Region: game.EU, PlayMap: game.POLUS}
+	if err := PushLobbyJob(ctx, client, "code", lobby); err != nil {
+		t.Fatal(err)
+	}
+	if err := PushStateJob(ctx, client, "code", game.DISCUSS); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := PopJob(ctx, client, "code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := job.DecodeLobby()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded != lobby {
+		t.Errorf("expected %+v, got %+v", lobby, decoded)
+	}
+
+	job, err = PopJob(ctx, client, "code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := job.DecodePlayer(); !errors.Is(err, ErrJobTypeMismatch) {
+		t.Errorf("expected ErrJobTypeMismatch decoding a state job as a player, got %v", err)
+	}
+	phase, err := job.DecodePhase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if phase != game.DISCUSS {
+		t.Errorf("expected DISCUSS, got %d", phase)
+	}
+}
+
+func TestDecodeMismatchedShape(t *testing.T) {
+	// a player payload mislabelled as a lobby job
+	job := Job{JobType: LobbyJob, Payload: `{"Action":0,"Name":"red","Color":0,"IsDead":false,"Disconnected":false}`}
+	if _, err := job.DecodeLobby(); !errors.Is(err, game.ErrPayloadShape) {
+		t.Errorf("expected ErrPayloadShape, got %v", err)
+	}
+
+	job = Job{JobType: StateJob, Payload: "tasks"}
+	if _, err := job.DecodePhase(); !errors.Is(err, game.ErrPayloadShape) {
+		t.Errorf("expected ErrPayloadShape for a non-integer phase, got %v", err)
+	}
+
+	job = Job{JobType: ConnectionJob, Payload: 1.0}
+	if _, err := job.DecodeConnection(); !errors.Is(err, game.ErrPayloadShape) {
+		t.Errorf("expected ErrPayloadShape for a non-string payload, got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/das08/utils/pkg/queue"
+	"github.com/das08/utils/pkg/rediskey"
+)
+
+func notifyChannel(connCode string) string {
+	return rediskey.JobNamespace + connCode + ":notify"
+}
+
+func ackChannel(connCode string) string {
+	return rediskey.JobNamespace + connCode + ":ack"
+}
+
+// JobQueue is the job API on top of any queue.Broker. The package-level functions are a JobQueue over Redis; use
+// queue.NewMemory() to run without a Redis server.
+type JobQueue struct {
+	broker queue.Broker
+}
+
+func NewJobQueue(broker queue.Broker) *JobQueue {
+	return &JobQueue{broker: broker}
+}
+
+func (q *JobQueue) Push(ctx context.Context, connCode string, jobType JobType, payload string) error {
+	job := Job{
+		JobType: jobType,
+		Payload: payload,
+		ID:      newJobID(),
+	}
+	jBytes, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.broker.RPushTTL(ctx, rediskey.JobNamespace+connCode, JobTTLSeconds*time.Second, string(jBytes))
+	if err != nil {
+		return err
+	}
+	q.notify(ctx, connCode)
+	return nil
+}
+
+// Pop returns the next job, or queue.Nil if there isn't one
+func (q *JobQueue) Pop(ctx context.Context, connCode string) (Job, error) {
+	return q.decode(q.broker.LPop(ctx, rediskey.JobNamespace+connCode))
+}
+
+// BlockingPop is Pop, but waits up to timeout for a job to be pushed
+func (q *JobQueue) BlockingPop(ctx context.Context, connCode string, timeout time.Duration) (Job, error) {
+	return q.decode(q.broker.BLPop(ctx, timeout, rediskey.JobNamespace+connCode))
+}
+
+// Claim is the at-least-once counterpart to Pop. The job is leased to consumer until AckJob is called, and if that
+// doesn't happen within visibility, RequeueExpired puts it back on the queue for another consumer. Returns queue.Nil if
+// the queue is empty.
+func (q *JobQueue) Claim(ctx context.Context, connCode, consumer string, visibility time.Duration) (Job, error) {
+	lease, err := q.broker.Claim(ctx, rediskey.JobNamespace+connCode, consumer, visibility)
+	j, err := q.decode(lease.Value, err)
+	j.lease = lease
+	return j, err
+}
+
+// AckJob marks a job returned by Claim as handled, so it will never be redelivered
+func (q *JobQueue) AckJob(ctx context.Context, connCode string, job Job) error {
+	if job.lease.Token == "" {
+		return errors.New("job was not returned by Claim")
+	}
+	return q.broker.Ack(ctx, rediskey.JobNamespace+connCode, job.lease)
+}
+
+// RequeueExpired returns every job whose visibility timeout has passed to the front of the queue, and notifies
+// subscribers if any were requeued
+func (q *JobQueue) RequeueExpired(ctx context.Context, connCode string) (int64, error) {
+	count, err := q.broker.Requeue(ctx, rediskey.JobNamespace+connCode)
+	if count > 0 {
+		q.notify(ctx, connCode)
+	}
+	return count, err
+}
+
+func (q *JobQueue) decode(str string, err error) (Job, error) {
+	j := Job{}
+	if err != nil {
+		return j, err
+	}
+	err = json.Unmarshal([]byte(str), &j)
+	return j, err
+}
+
+func (q *JobQueue) notify(ctx context.Context, connCode string) {
+	_ = q.broker.Publish(ctx, notifyChannel(connCode), "1")
+}
+
+// Subscribe is notified every time a job is pushed for connCode
+func (q *JobQueue) Subscribe(ctx context.Context, connCode string) (queue.Subscription, error) {
+	return q.broker.Subscribe(ctx, notifyChannel(connCode))
+}
+
+func (q *JobQueue) Ack(ctx context.Context, connCode string) error {
+	return q.broker.Publish(ctx, ackChannel(connCode), "1")
+}
+
+func (q *JobQueue) AckSubscribe(ctx context.Context, connCode string) (queue.Subscription, error) {
+	return q.broker.Subscribe(ctx, ackChannel(connCode))
+}
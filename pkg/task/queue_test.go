@@ -0,0 +1,72 @@
+package task
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/das08/utils/pkg/queue"
+)
+
+func TestJobQueueInMemory(t *testing.T) {
+	ctx := context.Background()
+	q := NewJobQueue(queue.NewMemory())
+
+	sub, err := q.Subscribe(ctx, "ABCDEF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	if err := q.Push(ctx, "ABCDEF", PlayerJob, "payload"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-sub.Channel():
+	case <-time.After(time.Second):
+		t.Error("subscriber was not notified of the pushed job")
+	}
+
+	job, err := q.BlockingPop(ctx, "ABCDEF", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.JobType != PlayerJob || job.Payload != "payload" {
+		t.Errorf("unexpected job popped: %+v", job)
+	}
+	if _, err := q.Pop(ctx, "ABCDEF"); err != queue.Nil {
+		t.Errorf("expected queue.Nil from an empty queue, got %v", err)
+	}
+}
+
+func TestJobQueueClaimInMemory(t *testing.T) {
+	ctx := context.Background()
+	q := NewJobQueue(queue.NewMemory())
+
+	if err := q.Push(ctx, "ABCDEF", StateJob, "1"); err != nil {
+		t.Fatal(err)
+	}
+	job, err := q.Claim(ctx, "ABCDEF", "crashed", time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Millisecond * 5)
+	if count, err := q.RequeueExpired(ctx, "ABCDEF"); err != nil || count != 1 {
+		t.Fatalf("expected the job to be requeued, got %d, %v", count, err)
+	}
+	if err := q.AckJob(ctx, "ABCDEF", job); err != ErrJobNotInFlight {
+		t.Errorf("expected ErrJobNotInFlight acking a redelivered job, got %v", err)
+	}
+
+	redelivered, err := q.Claim(ctx, "ABCDEF", "replacement", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redelivered.ID != job.ID {
+		t.Errorf("expected job %s to be redelivered, got %s", job.ID, redelivered.ID)
+	}
+	if err := q.AckJob(ctx, "ABCDEF", redelivered); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,54 @@
+package task
+
+import (
+	"context"
+	"time"
+
+	"github.com/das08/utils/pkg/logging"
+	"github.com/das08/utils/pkg/queue"
+	"github.com/go-redis/redis/v8"
+)
+
+// DefaultVisibilityTimeout is how long a claimed job may go unacknowledged before it is redelivered
+const DefaultVisibilityTimeout = time.Second * 30
+
+var ErrJobNotInFlight = queue.ErrNotLeased
+
+// ClaimJob is the at-least-once counterpart to PopJob. The job is moved onto a processing list owned by consumer, and
+// stays there until AckJob is called. If that doesn't happen within visibility, RequeueExpiredJobs puts it back on the
+// queue for another consumer. Returns redis.Nil if the queue is empty.
+func ClaimJob(ctx context.Context, redis *redis.Client, connCode, consumer string, visibility time.Duration) (Job, error) {
+	return NewJobQueue(queue.NewRedis(redis)).Claim(ctx, connCode, consumer, visibility)
+}
+
+// AckJob marks a job returned by ClaimJob as handled, so it will never be redelivered
+func AckJob(ctx context.Context, redis *redis.Client, connCode string, job Job) error {
+	return NewJobQueue(queue.NewRedis(redis)).AckJob(ctx, connCode, job)
+}
+
+// RequeueExpiredJobs returns every job whose visibility timeout has passed to the front of the queue, and notifies
+// subscribers if any were requeued
+func RequeueExpiredJobs(ctx context.Context, redis *redis.Client, connCode string) (int64, error) {
+	return NewJobQueue(queue.NewRedis(redis)).RequeueExpired(ctx, connCode)
+}
+
+// ReapJobs calls RequeueExpiredJobs every interval until the context is cancelled. Failures are reported to l (usually
+// logging.Default()) and retried on the next tick.
+func ReapJobs(ctx context.Context, redis *redis.Client, connCode string, interval time.Duration, l logging.Logger) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			count, err := RequeueExpiredJobs(ctx, redis, connCode)
+			if err != nil {
+				logging.Printf(l, "Failed to requeue expired jobs for %s: %v", connCode, err)
+			} else if count > 0 {
+				logging.Printf(l, "Requeued %d expired jobs for %s", count, connCode)
+			}
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/das08/utils/pkg/queue"
+	"github.com/das08/utils/pkg/rediskey"
+	"github.com/go-redis/redis/v8"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return mr, client
+}
+
+func TestClaimAndAckJob(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+
+	if err := PushJob(ctx, client, "ABCDEF", LobbyJob, "lobby"); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := ClaimJob(ctx, client, "ABCDEF", "worker1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.JobType != LobbyJob || job.Payload != "lobby" {
+		t.Errorf("unexpected job claimed: %+v", job)
+	}
+	if mr.Exists(rediskey.JobNamespace + "ABCDEF") {
+		t.Error("claimed job should no longer be on the queue")
+	}
+	if items, _ := mr.List(queue.ProcessingKey(rediskey.JobNamespace+"ABCDEF", "worker1")); len(items) != 1 {
+		t.Error("claimed job should be on the consumer's processing list")
+	}
+
+	_, err = ClaimJob(ctx, client, "ABCDEF", "worker1", time.Minute)
+	if !errors.Is(err, redis.Nil) {
+		t.Errorf("expected redis.Nil claiming from an empty queue, got %v", err)
+	}
+
+	if err := AckJob(ctx, client, "ABCDEF", job); err != nil {
+		t.Fatal(err)
+	}
+	if mr.Exists(queue.ProcessingKey(rediskey.JobNamespace+"ABCDEF", "worker1")) {
+		t.Error("acked job should be removed from the processing list")
+	}
+	if err := AckJob(ctx, client, "ABCDEF", job); !errors.Is(err, ErrJobNotInFlight) {
+		t.Errorf("expected ErrJobNotInFlight acking twice, got %v", err)
+	}
+	if err := AckJob(ctx, client, "ABCDEF", Job{}); err == nil {
+		t.Error("expected an error acking a job that was never claimed")
+	}
+}
+
+func TestRequeueExpiredJobs(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+
+	for _, payload := range []string{"first", "second", "third"} {
+		if err := PushJob(ctx, client, "ABCDEF", StateJob, payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first, err := ClaimJob(ctx, client, "ABCDEF", "crashed", time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := ClaimJob(ctx, client, "ABCDEF", "crashed", time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ClaimJob(ctx, client, "ABCDEF", "alive", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Millisecond * 5)
+	count, err := RequeueExpiredJobs(ctx, client, "ABCDEF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatalf("expected 2 jobs to be requeued, got %d", count)
+	}
+
+	if err := AckJob(ctx, client, "ABCDEF", first); !errors.Is(err, ErrJobNotInFlight) {
+		t.Errorf("expected ErrJobNotInFlight acking a redelivered job, got %v", err)
+	}
+
+	// redelivered jobs keep their original order, ahead of anything pushed since
+	for _, expected := range []Job{first, second} {
+		job, err := ClaimJob(ctx, client, "ABCDEF", "replacement", time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.ID != expected.ID || job.Payload != expected.Payload {
+			t.Errorf("expected job %s to be redelivered, got %s", expected.ID, job.ID)
+		}
+	}
+
+	count, err = RequeueExpiredJobs(ctx, client, "ABCDEF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("jobs within their visibility timeout should not be requeued, got %d", count)
+	}
+}
+
+func TestClaimIdenticalJobs(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+
+	// two identical jobs from a producer that doesn't set IDs
+	raw := `{"type":2,"payload":"state"}`
+	client.RPush(ctx, rediskey.JobNamespace+"ABCDEF", raw, raw)
+
+	first, err := ClaimJob(ctx, client, "ABCDEF", "worker1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := ClaimJob(ctx, client, "ABCDEF", "worker1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Payload != "state" || second.Payload != "state" {
+		t.Fatalf("unexpected jobs claimed: %+v, %+v", first, second)
+	}
+
+	if err := AckJob(ctx, client, "ABCDEF", first); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := client.ZCard(ctx, queue.LeasesKey(rediskey.JobNamespace+"ABCDEF")).Result(); n != 1 {
+		t.Errorf("acking one job should leave the other in flight, got %d in flight", n)
+	}
+	if err := AckJob(ctx, client, "ABCDEF", second); err != nil {
+		t.Errorf("expected the identical job to be acked separately, got %v", err)
+	}
+}
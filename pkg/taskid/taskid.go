@@ -0,0 +1,104 @@
+package taskid
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IDs are ULIDs: a 48-bit millisecond timestamp followed by 80 random bits, in Crockford's base32. They sort by
+// creation time, and the randomness keeps IDs made in the same millisecond by different processes apart.
+const (
+	Length     = 26
+	timeLength = 10
+	alphabet   = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	maxTime    = 1<<48 - 1
+)
+
+var ErrInvalidID = errors.New("not a valid task ID")
+
+var (
+	lock     sync.Mutex
+	lastTime uint64
+	lastRand [10]byte
+)
+
+// New returns a unique ID. IDs made by the same process are strictly increasing, even within a single millisecond.
+func New() string {
+	lock.Lock()
+	defer lock.Unlock()
+
+	ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	// if the clock went backwards, stay on the last timestamp so IDs keep increasing
+	if ms <= lastTime {
+		ms = lastTime
+		if !increment(&lastRand) {
+			ms++
+			_, _ = rand.Read(lastRand[:])
+		}
+	} else {
+		_, _ = rand.Read(lastRand[:])
+	}
+	lastTime = ms
+
+	var id [16]byte
+	for i := 0; i < 6; i++ {
+		id[i] = byte(ms >> (40 - 8*i))
+	}
+	copy(id[6:], lastRand[:])
+	return encode(id)
+}
+
+// increment adds one to r as a big-endian number, returning false if it overflowed
+func increment(r *[10]byte) bool {
+	for i := len(r) - 1; i >= 0; i-- {
+		r[i]++
+		if r[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func encode(id [16]byte) string {
+	var hi, lo uint64
+	for i := 0; i < 8; i++ {
+		hi = hi<<8 | uint64(id[i])
+		lo = lo<<8 | uint64(id[i+8])
+	}
+
+	// 26 characters hold 130 bits, so the first character only carries the top 3 bits
+	out := make([]byte, Length)
+	for i := Length - 1; i >= 0; i-- {
+		out[i] = alphabet[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out)
+}
+
+// Time returns when an ID was created, to millisecond precision
+func Time(id string) (time.Time, error) {
+	if len(id) != Length {
+		return time.Time{}, ErrInvalidID
+	}
+	var ms uint64
+	for _, c := range strings.ToUpper(id[:timeLength]) {
+		idx := strings.IndexRune(alphabet, c)
+		if idx < 0 {
+			return time.Time{}, ErrInvalidID
+		}
+		ms = ms<<5 | uint64(idx)
+	}
+	if ms > maxTime {
+		return time.Time{}, ErrInvalidID
+	}
+	for _, c := range strings.ToUpper(id[timeLength:]) {
+		if !strings.ContainsRune(alphabet, c) {
+			return time.Time{}, ErrInvalidID
+		}
+	}
+	return time.Unix(0, int64(ms)*int64(time.Millisecond)), nil
+}
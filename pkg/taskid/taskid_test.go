@@ -0,0 +1,58 @@
+package taskid
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestNewIsUniqueAndSorted(t *testing.T) {
+	ids := make([]string, 10000)
+	seen := make(map[string]bool, len(ids))
+	for i := range ids {
+		ids[i] = New()
+		if len(ids[i]) != Length {
+			t.Fatalf("expected an ID of length %d, got %s", Length, ids[i])
+		}
+		if seen[ids[i]] {
+			t.Fatalf("duplicate ID %s", ids[i])
+		}
+		seen[ids[i]] = true
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Error("IDs should sort in the order they were made")
+	}
+}
+
+func TestTime(t *testing.T) {
+	before := time.Now().Truncate(time.Millisecond)
+	id := New()
+	after := time.Now()
+
+	created, err := Time(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Before(before) || created.After(after) {
+		t.Errorf("expected %s to be between %s and %s", created, before, after)
+	}
+
+	for _, invalid := range []string{"", "abc", "8ZZZZZZZZZZZZZZZZZZZZZZZZZ", "01ARZ3NDEKTSV4RRFFQ69G5FAU"} {
+		if _, err := Time(invalid); err != ErrInvalidID {
+			t.Errorf("expected ErrInvalidID for %q, got %v", invalid, err)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	var max [16]byte
+	for i := range max {
+		max[i] = 0xff
+	}
+	if encode(max) != "7ZZZZZZZZZZZZZZZZZZZZZZZZZ" {
+		t.Errorf("unexpected encoding of the maximum ID: %s", encode(max))
+	}
+	if encode([16]byte{}) != "00000000000000000000000000" {
+		t.Errorf("unexpected encoding of the zero ID: %s", encode([16]byte{}))
+	}
+}
@@ -0,0 +1,73 @@
+package token
+
+import (
+	"context"
+	"time"
+
+	"github.com/das08/utils/pkg/lock"
+	"github.com/das08/utils/pkg/logging"
+	"github.com/das08/utils/pkg/rediskey"
+	"github.com/go-redis/redis/v8"
+)
+
+// IdentifyWindow is the period Discord's max_concurrency applies to
+const IdentifyWindow = time.Second * 5
+
+// IdentifyCoordinator enforces Discord's identify rate limit across processes: each bucket (shard_id % max_concurrency)
+// of a token may identify once per Window.
+type IdentifyCoordinator struct {
+	client *redis.Client
+	Window time.Duration
+	Logger logging.Logger
+}
+
+func NewIdentifyCoordinator(client *redis.Client) *IdentifyCoordinator {
+	return &IdentifyCoordinator{
+		client: client,
+		Window: IdentifyWindow,
+		Logger: logging.Default(),
+	}
+}
+
+// IdentifyKey is the lock for a shard's bucket. Unsharded bots (max_concurrency of 1) share the original
+// BotTokenIdentifyLock, so they still interoperate with LockForToken and WaitForToken.
+func IdentifyKey(token string, shardID, maxConcurrency int) string {
+	if maxConcurrency <= 1 {
+		return rediskey.BotTokenIdentifyLock(token)
+	}
+	return rediskey.BotTokenIdentifyBucketLock(token, shardID%maxConcurrency)
+}
+
+// Acquire blocks until the shard is allowed to identify, and returns how long that took. The bucket is left locked
+// until the window expires, rather than released, since that's what Discord's limit requires.
+func (c *IdentifyCoordinator) Acquire(ctx context.Context, token string, shardID, maxConcurrency int) (time.Duration, error) {
+	start := time.Now()
+	l := lock.New(c.client, IdentifyKey(token, shardID, maxConcurrency), c.Window)
+
+	for {
+		acquired, err := l.TryAcquire(ctx)
+		if err != nil {
+			return time.Since(start), err
+		}
+		if acquired {
+			return time.Since(start), nil
+		}
+
+		// sleep until the current holder's window ends, rather than polling
+		wait, err := l.TTL(ctx)
+		if err != nil {
+			return time.Since(start), err
+		}
+		if wait <= 0 {
+			// the key just expired (or was set without a TTL); try again soon either way
+			wait = c.Window / 10
+		}
+		logging.Printf(c.Logger, "Shard %d waiting %s for its identify bucket", shardID, wait)
+
+		select {
+		case <-ctx.Done():
+			return time.Since(start), ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
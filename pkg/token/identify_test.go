@@ -0,0 +1,67 @@
+package token
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/das08/utils/pkg/rediskey"
+	"github.com/go-redis/redis/v8"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return mr, client
+}
+
+func TestIdentifyKey(t *testing.T) {
+	if IdentifyKey("token", 3, 1) != rediskey.BotTokenIdentifyLock("token") {
+		t.Error("unsharded identifies should use the original token lock")
+	}
+	if IdentifyKey("token", 1, 16) == IdentifyKey("token", 2, 16) {
+		t.Error("shards in different buckets should not share a lock")
+	}
+	if IdentifyKey("token", 1, 16) != IdentifyKey("token", 17, 16) {
+		t.Error("shards in the same bucket should share a lock")
+	}
+}
+
+func TestIdentifyCoordinator(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+	c := NewIdentifyCoordinator(client)
+	c.Window = time.Millisecond * 50
+
+	if _, err := c.Acquire(ctx, "token", 0, 2); err != nil {
+		t.Fatal(err)
+	}
+	waited, err := c.Acquire(ctx, "token", 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if waited > time.Millisecond*20 {
+		t.Errorf("a shard in a free bucket shouldn't wait, waited %s", waited)
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, time.Millisecond*10)
+	defer cancel()
+	if _, err := c.Acquire(timeout, "token", 2, 2); err != context.DeadlineExceeded {
+		t.Errorf("expected the context deadline while the bucket is locked, got %v", err)
+	}
+
+	// miniredis only expires keys when told to
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		mr.FastForward(c.Window)
+	}()
+	waited, err = c.Acquire(ctx, "token", 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if waited < time.Millisecond*20 {
+		t.Errorf("expected to wait for the bucket's window to end, waited %s", waited)
+	}
+}
@@ -2,33 +2,123 @@ package token
 
 import (
 	"context"
+	"errors"
 	"log"
 	"time"
 
+	"github.com/das08/utils/pkg/lock"
+	"github.com/das08/utils/pkg/logging"
 	"github.com/das08/utils/pkg/rediskey"
 	"github.com/go-redis/redis/v8"
 )
 
+var ErrTokenLocked = errors.New("token is locked for identifying")
+
+// FailurePolicy decides what a Locker assumes about a token when Redis can't be reached
+type FailurePolicy int
+
+const (
+	// FailOpen treats the token as free, so an outage can't stop the bot from identifying (the original behavior)
+	FailOpen FailurePolicy = iota
+	// FailClosed treats the token as locked, so an outage can't cause an identify storm
+	FailClosed
+)
+
+// Locker is the context-aware form of LockForToken, WaitForToken and IsTokenLocked
+type Locker struct {
+	client *redis.Client
+	Policy FailurePolicy
+	Logger logging.Logger
+	// Window is how long Lock holds the token
+	Window time.Duration
+	// RetryInterval is how long Wait sleeps when it can't tell how long the token will stay locked
+	RetryInterval time.Duration
+}
+
+func NewLocker(client *redis.Client) *Locker {
+	return &Locker{
+		client:        client,
+		Policy:        FailOpen,
+		Logger:        logging.Default(),
+		Window:        IdentifyWindow,
+		RetryInterval: time.Second * 5,
+	}
+}
+
+func (l *Locker) logf(format string, v ...interface{}) {
+	logging.Printf(l.Logger, format, v...)
+}
+
+// Lock takes the token for Window, returning ErrTokenLocked if someone else already holds it. The returned lock can
+// release the token early, or extend the window; otherwise it simply expires.
+func (l *Locker) Lock(ctx context.Context, token string) (*lock.Lock, error) {
+	l.logf("Locking token for %s", l.Window)
+	tokenLock := lock.ForTokenIdentify(l.client, token, l.Window)
+	acquired, err := tokenLock.TryAcquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		return nil, ErrTokenLocked
+	}
+	return tokenLock, nil
+}
+
+// IsLocked reports whether the token is locked. On a Redis error, the result follows the Policy, and the error is
+// returned alongside it.
+func (l *Locker) IsLocked(ctx context.Context, token string) (bool, error) {
+	v, err := l.client.Exists(ctx, rediskey.BotTokenIdentifyLock(token)).Result()
+	if err != nil {
+		return l.Policy == FailClosed, err
+	}
+
+	return v == 1, nil //=1 means the rediskey is present, hence locked
+}
+
+// Wait blocks until the token is unlocked, or the context is done. If Redis fails, FailOpen returns the error straight
+// away (the token should be treated as free), while FailClosed keeps waiting for Redis to come back.
+func (l *Locker) Wait(ctx context.Context, token string) error {
+	for {
+		ttl, err := l.client.PTTL(ctx, rediskey.BotTokenIdentifyLock(token)).Result()
+		wait := ttl
+		switch {
+		case err != nil && l.Policy == FailOpen:
+			l.logf("Failed to check token lock, assuming it's free: %s", err)
+			return err
+		case err != nil:
+			l.logf("Failed to check token lock, assuming it's locked: %s", err)
+			wait = l.RetryInterval
+		case ttl == -2:
+			// PTTL returns -2 when the key doesn't exist
+			return nil
+		case ttl <= 0:
+			wait = l.RetryInterval
+		}
+
+		l.logf("Sleeping for %s while waiting for token to become available", wait)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// LockForToken always (re)starts the token's window, even if it's already locked. Use Locker.Lock to only take a free
+// token.
 func LockForToken(client *redis.Client, token string) {
 	log.Println("Locking token for 5 seconds")
-	err := client.Set(context.Background(), rediskey.BotTokenIdentifyLock(token), "", time.Second*5).Err()
+	err := client.Set(context.Background(), rediskey.BotTokenIdentifyLock(token), "", IdentifyWindow).Err()
 	if err != nil {
 		log.Println(err)
 	}
 }
 
 func WaitForToken(client *redis.Client, token string) {
-	for IsTokenLocked(client, token) {
-		log.Println("Sleeping for 5 seconds while waiting for token to become available")
-		time.Sleep(time.Second * 5)
-	}
+	_ = NewLocker(client).Wait(context.Background(), token)
 }
 
 func IsTokenLocked(client *redis.Client, token string) bool {
-	v, err := client.Exists(context.Background(), rediskey.BotTokenIdentifyLock(token)).Result()
-	if err != nil {
-		return false
-	}
-
-	return v == 1 //=1 means the rediskey is present, hence locked
+	locked, _ := NewLocker(client).IsLocked(context.Background(), token)
+	return locked
 }
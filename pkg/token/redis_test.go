@@ -0,0 +1,105 @@
+package token
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/das08/utils/pkg/rediskey"
+)
+
+type testLogger struct {
+	lines []string
+}
+
+func (l *testLogger) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestLocker(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+	logger := &testLogger{}
+	l := NewLocker(client)
+	l.Logger = logger
+	l.Window = time.Millisecond * 30
+
+	if err := l.Wait(ctx, "token"); err != nil {
+		t.Fatalf("waiting on a free token should return immediately, got %v", err)
+	}
+	held, err := l.Lock(ctx, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Lock(ctx, "token"); err != ErrTokenLocked {
+		t.Errorf("expected ErrTokenLocked locking twice, got %v", err)
+	}
+	if locked, err := l.IsLocked(ctx, "token"); err != nil || !locked {
+		t.Errorf("expected the token to be locked, got %v, %v", locked, err)
+	}
+	if err := held.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if locked, _ := l.IsLocked(ctx, "token"); locked {
+		t.Error("expected the token to be free once released")
+	}
+	if _, err := l.Lock(ctx, "token"); err != nil {
+		t.Fatal(err)
+	}
+
+	// miniredis only expires keys when told to
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		mr.FastForward(l.Window)
+	}()
+	timeout, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := l.Wait(timeout, "token"); err != nil {
+		t.Fatalf("expected Wait to return once the lock expired, got %v", err)
+	}
+	if len(logger.lines) == 0 {
+		t.Error("expected the locker to log through the configured logger")
+	}
+}
+
+func TestLockForTokenRefreshes(t *testing.T) {
+	mr, client := newTestRedis(t)
+
+	LockForToken(client, "token")
+	mr.FastForward(IdentifyWindow - time.Second)
+	// locking an already locked token starts its window again, like it always has
+	LockForToken(client, "token")
+	if ttl := mr.TTL(rediskey.BotTokenIdentifyLock("token")); ttl != IdentifyWindow {
+		t.Errorf("expected the window to be refreshed to %s, got %s", IdentifyWindow, ttl)
+	}
+}
+
+func TestLockerFailurePolicy(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+	mr.Close()
+
+	l := NewLocker(client)
+	l.Logger = nil
+	if locked, err := l.IsLocked(ctx, "token"); err == nil || locked {
+		t.Errorf("fail open should report unlocked with an error, got %v, %v", locked, err)
+	}
+	if err := l.Wait(ctx, "token"); err == nil {
+		t.Error("fail open should return the Redis error from Wait")
+	}
+	if IsTokenLocked(client, "token") {
+		t.Error("IsTokenLocked should keep failing open")
+	}
+
+	l.Policy = FailClosed
+	l.RetryInterval = time.Millisecond * 10
+	if locked, err := l.IsLocked(ctx, "token"); err == nil || !locked {
+		t.Errorf("fail closed should report locked with an error, got %v, %v", locked, err)
+	}
+	timeout, cancel := context.WithTimeout(ctx, time.Millisecond*50)
+	defer cancel()
+	if err := l.Wait(timeout, "token"); err != context.DeadlineExceeded {
+		t.Errorf("fail closed should keep waiting until the context is done, got %v", err)
+	}
+}